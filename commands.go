@@ -0,0 +1,1177 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"linkedin-automation/pkg/config"
+	"linkedin-automation/pkg/connection"
+	"linkedin-automation/pkg/database"
+	"linkedin-automation/pkg/engagement"
+	"linkedin-automation/pkg/invitations"
+	"linkedin-automation/pkg/logger"
+	"linkedin-automation/pkg/messaging"
+	"linkedin-automation/pkg/profileurl"
+	"linkedin-automation/pkg/query"
+	"linkedin-automation/pkg/retry"
+	"linkedin-automation/pkg/search"
+	"linkedin-automation/pkg/templates"
+)
+
+// usage prints the command line help
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [command]\n\n", os.Args[0])
+	fmt.Fprintln(out, "Commands:")
+	fmt.Fprintln(out, "  invitations inbound [-dry-run]   triage received connection invitations")
+	fmt.Fprintln(out, "  deadletter list [-action a]      show actions that exhausted their retries")
+	fmt.Fprintln(out, "  deadletter requeue [-action a] [-all | id...]")
+	fmt.Fprintln(out, "                                   move dead-lettered actions back to the retry queue")
+	fmt.Fprintln(out, "  suppress add|remove [-reason r] <url>...")
+	fmt.Fprintln(out, "                                   manage the do-not-contact list")
+	fmt.Fprintln(out, "  suppress list                    show the do-not-contact list")
+	fmt.Fprintln(out, "  inbox sync                       read recent conversations and record replies")
+	fmt.Fprintln(out, "  inbox stats                      show the reply rate of messaged profiles")
+	fmt.Fprintln(out, "  search [filters]                 run a one-off search; see \"search -h\" for filters")
+	fmt.Fprintln(out, "  search -resume [-run id]         continue the latest unfinished search from its checkpoint")
+	fmt.Fprintln(out, "  search save -name n [-every d] [filters]")
+	fmt.Fprintln(out, "                                   save a named search")
+	fmt.Fprintln(out, "  search list                      show saved searches and when they last ran")
+	fmt.Fprintln(out, "  search run [-due] [name...]      run saved searches and report the new profiles")
+	fmt.Fprintln(out, "  search delete name               remove a saved search")
+	fmt.Fprintln(out, "  search history [-name n] [-limit n] [-by-query]")
+	fmt.Fprintln(out, "                                   show past search runs and what each one found")
+	fmt.Fprintln(out, "  search company [-title t]... <company-url-or-name>")
+	fmt.Fprintln(out, "                                   find a company's employees and link them to it")
+	fmt.Fprintln(out, "  engagement collect <post-url>... save the people who reacted to or commented on posts")
+	fmt.Fprintln(out, "  engagement list [-post url] [-kind reaction|comment]")
+	fmt.Fprintln(out, "                                   show collected reactions and comments")
+	fmt.Fprintln(out, "  review                           approve, edit or reject drafts one by one")
+	fmt.Fprintln(out, "  review list [-status s]          show drafts waiting for approval")
+	fmt.Fprintln(out, "  review approve|reject id...")
+	fmt.Fprintln(out, "  review edit -body text id        replace a draft's text and approve it")
+	fmt.Fprintln(out, "  templates preview [-profile url] [-limit n]")
+	fmt.Fprintln(out, "                                   render notes and messages against stored profiles")
+	fmt.Fprintln(out, "  templates lint [-empty-threshold f]")
+	fmt.Fprintln(out, "                                   check templates for unknown or blank placeholders and length")
+	fmt.Fprintln(out, "  db dedupe [-dry-run]             merge profiles stored under different forms of the same URL")
+	fmt.Fprintln(out, "\nWithout a command, the operation selected by -mode is run.")
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+// runCommand dispatches a subcommand given after the flags
+func runCommand(cfg *config.Config, db *database.DB, args []string) error {
+	switch args[0] {
+	case "invitations":
+		return runInvitations(cfg, db, args[1:])
+	case "deadletter":
+		return runDeadLetter(db, args[1:])
+	case "suppress":
+		return runSuppress(db, args[1:])
+	case "inbox":
+		return runInbox(cfg, db, args[1:])
+	case "search":
+		return runSearchCommand(cfg, db, args[1:])
+	case "engagement":
+		return runEngagement(cfg, db, args[1:])
+	case "review":
+		return runReview(db, args[1:])
+	case "templates":
+		return runTemplates(cfg, db, args[1:])
+	case "db":
+		return runDB(db, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// runInvitations handles "invitations inbound"
+func runInvitations(cfg *config.Config, db *database.DB, args []string) error {
+	if len(args) == 0 || args[0] != "inbound" {
+		return fmt.Errorf("usage: invitations inbound [-dry-run]")
+	}
+
+	fs := flag.NewFlagSet("invitations inbound", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "Record decisions without accepting or ignoring invitations")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	authInstance, err := startSession(cfg)
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+	defer authInstance.Close()
+
+	inv := invitations.NewInvitations(cfg, authInstance.GetPage(), authInstance.GetStealth(), db)
+	summary, err := inv.TriageInbound(*dryRun)
+	if err != nil {
+		return fmt.Errorf("inbound triage failed: %w", err)
+	}
+
+	logger.Info("Inbound invitations processed",
+		"accepted", summary.Accepted,
+		"ignored", summary.Ignored,
+		"review", summary.Review,
+		"failed", summary.Failed,
+	)
+
+	return nil
+}
+
+// runEngagement handles "engagement collect" and "engagement list"
+func runEngagement(cfg *config.Config, db *database.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: engagement collect|list [flags] [post-url...]")
+	}
+
+	fs := flag.NewFlagSet("engagement "+args[0], flag.ContinueOnError)
+	post := fs.String("post", "", "Only show engagement with this post")
+	kind := fs.String("kind", "", "Only show reactions or comments")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "collect":
+		if fs.NArg() == 0 {
+			return fmt.Errorf("usage: engagement collect post-url...")
+		}
+
+		// Check every URL before logging in
+		var posts []string
+		for _, raw := range fs.Args() {
+			postURL, err := engagement.CanonicalPostURL(raw)
+			if err != nil {
+				return err
+			}
+			posts = append(posts, postURL)
+		}
+
+		authInstance, err := startSession(cfg)
+		if err != nil {
+			return fmt.Errorf("login failed: %w", err)
+		}
+		defer authInstance.Close()
+
+		e := engagement.NewEngagement(cfg, authInstance.GetPage(), authInstance.GetStealth(), db)
+		failed := 0
+		for _, postURL := range posts {
+			summary, err := e.CollectPost(postURL)
+			if err != nil {
+				logger.Warn("Failed to collect post engagement",
+					"post_url", postURL,
+					"error", err,
+				)
+				failed++
+				continue
+			}
+			fmt.Printf("%s: %d reaction(s), %d comment(s), %d new profile(s)\n",
+				summary.PostURL, summary.Reactions, summary.Comments, summary.NewProfiles)
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d of %d post(s) failed", failed, len(posts))
+		}
+		return nil
+
+	case "list":
+		if *kind != "" && *kind != database.EngagementReaction && *kind != database.EngagementComment {
+			return fmt.Errorf("unknown kind %q, expected reaction or comment", *kind)
+		}
+
+		postURL := *post
+		if postURL != "" {
+			var err error
+			if postURL, err = engagement.CanonicalPostURL(postURL); err != nil {
+				return err
+			}
+		}
+
+		engagements, err := db.GetPostEngagements(postURL, *kind)
+		if err != nil {
+			return fmt.Errorf("failed to list post engagement: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "FOUND\tPROFILE\tKIND\tREACTION / COMMENT\tPOST")
+		for _, e := range engagements {
+			detail := e.Reaction
+			if e.Kind == database.EngagementComment {
+				detail = truncate(strings.Join(strings.Fields(e.Comment), " "), 60)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.FoundAt.Local().Format(time.DateTime), e.ProfileURL,
+				e.Kind, detail, e.PostURL)
+		}
+		return w.Flush()
+
+	default:
+		return fmt.Errorf("unknown engagement subcommand %q", args[0])
+	}
+}
+
+// runDB handles "db dedupe"
+func runDB(db *database.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: db dedupe [-dry-run]")
+	}
+
+	fs := flag.NewFlagSet("db "+args[0], flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "Report what would be merged without changing anything")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "dedupe":
+		summary, err := db.DedupeProfiles(*dryRun)
+		if err != nil {
+			return fmt.Errorf("failed to dedupe profiles: %w", err)
+		}
+
+		fmt.Printf("Merged %d duplicate profile(s) in %d group(s), rewrote %d URL(s)\n",
+			summary.Merged, summary.Groups, summary.Rewritten)
+		fmt.Printf("Moved %d related row(s), merged %d conflicting row(s) into existing ones\n",
+			summary.RowsUpdated, summary.RowsMerged)
+		if *dryRun {
+			fmt.Println("Dry run: no changes were written")
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown db subcommand %q", args[0])
+	}
+}
+
+// runDeadLetter handles "deadletter list" and "deadletter requeue"
+func runDeadLetter(db *database.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: deadletter list|requeue [flags]")
+	}
+
+	fs := flag.NewFlagSet("deadletter "+args[0], flag.ContinueOnError)
+	action := fs.String("action", "", "Limit to one action type: connect or message")
+	all := fs.Bool("all", false, "Requeue every dead-lettered action")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		retries, err := db.GetActionRetries(*action, database.RetryStateDead)
+		if err != nil {
+			return fmt.Errorf("failed to list dead-lettered actions: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tACTION\tPROFILE\tATTEMPTS\tUPDATED\tLAST ERROR")
+		for _, r := range retries {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\t%s\n", r.ID, r.Action, r.ProfileURL, r.Attempts,
+				r.UpdatedAt.Local().Format(time.DateTime), r.LastError)
+		}
+		return w.Flush()
+
+	case "requeue":
+		if *all {
+			n, err := db.RequeueDeadActions(*action)
+			if err != nil {
+				return fmt.Errorf("failed to requeue actions: %w", err)
+			}
+			fmt.Printf("Requeued %d action(s)\n", n)
+			return nil
+		}
+
+		if fs.NArg() == 0 {
+			return fmt.Errorf("usage: deadletter requeue [-action a] [-all | id...]")
+		}
+
+		for _, arg := range fs.Args() {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid id %q", arg)
+			}
+
+			ok, err := db.RequeueActionRetry(id)
+			if err != nil {
+				return fmt.Errorf("failed to requeue action %d: %w", id, err)
+			}
+			if !ok {
+				return fmt.Errorf("no dead-lettered action with id %d", id)
+			}
+			fmt.Printf("Requeued action %d\n", id)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown deadletter subcommand %q", args[0])
+	}
+}
+
+// runSuppress handles "suppress add", "suppress remove" and "suppress list"
+func runSuppress(db *database.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: suppress add|remove|list [flags] [url...]")
+	}
+
+	fs := flag.NewFlagSet("suppress "+args[0], flag.ContinueOnError)
+	reason := fs.String("reason", "manual", "Why the profile must not be contacted")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		suppressions, err := db.GetSuppressions()
+		if err != nil {
+			return fmt.Errorf("failed to list suppressions: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "PROFILE\tREASON\tADDED")
+		for _, s := range suppressions {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", s.ProfileURL, s.Reason, s.CreatedAt.Local().Format(time.DateTime))
+		}
+		return w.Flush()
+
+	case "add":
+		if fs.NArg() == 0 {
+			return fmt.Errorf("usage: suppress add [-reason r] url...")
+		}
+		for _, profileURL := range fs.Args() {
+			profileURL = profileurl.Canonical(profileURL)
+			if err := db.AddSuppression(profileURL, *reason); err != nil {
+				return fmt.Errorf("failed to suppress %s: %w", profileURL, err)
+			}
+			fmt.Printf("Suppressed %s\n", profileURL)
+		}
+		return nil
+
+	case "remove":
+		if fs.NArg() == 0 {
+			return fmt.Errorf("usage: suppress remove url...")
+		}
+		for _, profileURL := range fs.Args() {
+			profileURL = profileurl.Canonical(profileURL)
+			ok, err := db.RemoveSuppression(profileURL)
+			if err != nil {
+				return fmt.Errorf("failed to remove suppression for %s: %w", profileURL, err)
+			}
+			if !ok {
+				return fmt.Errorf("%s is not suppressed", profileURL)
+			}
+			fmt.Printf("Removed suppression for %s\n", profileURL)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown suppress subcommand %q", args[0])
+	}
+}
+
+// runInbox handles "inbox sync" and "inbox stats"
+func runInbox(cfg *config.Config, db *database.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: inbox sync|stats")
+	}
+
+	switch args[0] {
+	case "sync":
+		authInstance, err := startSession(cfg)
+		if err != nil {
+			return fmt.Errorf("login failed: %w", err)
+		}
+		defer authInstance.Close()
+
+		msg := messaging.NewMessaging(cfg, authInstance.GetPage(), authInstance.GetStealth(), db)
+		summary, err := msg.SyncInbox()
+		if err != nil {
+			return fmt.Errorf("inbox sync failed: %w", err)
+		}
+
+		fmt.Printf("Synced %d thread(s), %d matched to profiles, %d new message(s), %d new repl(ies)\n",
+			summary.Threads, summary.Matched, summary.NewMessages, summary.NewReplies)
+		fmt.Printf("Labelled %d conversation(s), suppressed %d profile(s) that unsubscribed\n",
+			summary.Labeled, summary.Suppressed)
+		return printReplyStats(db)
+
+	case "stats":
+		return printReplyStats(db)
+
+	default:
+		return fmt.Errorf("unknown inbox subcommand %q", args[0])
+	}
+}
+
+func printReplyStats(db *database.DB) error {
+	stats, err := db.GetReplyStats()
+	if err != nil {
+		return fmt.Errorf("failed to compute reply rate: %w", err)
+	}
+
+	fmt.Printf("Reply rate: %d of %d conversation(s) (%.1f%%)\n",
+		stats.Replied, stats.Conversations, stats.Rate()*100)
+
+	counts, err := db.GetLabelCounts()
+	if err != nil {
+		return fmt.Errorf("failed to count reply labels: %w", err)
+	}
+
+	for _, label := range []string{
+		messaging.LabelInterested,
+		messaging.LabelNotInterested,
+		messaging.LabelOutOfOffice,
+		messaging.LabelReferral,
+		messaging.LabelUnsubscribe,
+	} {
+		fmt.Printf("  %-15s %d\n", label, counts[label])
+	}
+	return nil
+}
+
+// runTemplates handles "templates preview" and "templates lint"
+func runTemplates(cfg *config.Config, db *database.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: templates preview|lint [flags]")
+	}
+
+	fs := flag.NewFlagSet("templates "+args[0], flag.ContinueOnError)
+	profileURL := fs.String("profile", "", "Profile URL to render against (default: the most recent profiles)")
+	limit := fs.Int("limit", 3, "Number of recent profiles to preview when -profile is not set")
+	threshold := fs.Float64("empty-threshold", 0.1, "Warn when a placeholder is blank for more than this share of profiles")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "preview":
+		var profiles []*database.Profile
+		if *profileURL != "" {
+			profile, err := db.GetProfileByURL(profileurl.Canonical(*profileURL))
+			if err != nil {
+				return fmt.Errorf("profile %s not found: %w", *profileURL, err)
+			}
+			if profile == nil {
+				return fmt.Errorf("profile %s not found", *profileURL)
+			}
+			profiles = append(profiles, profile)
+		} else {
+			var err error
+			if profiles, err = db.GetProfiles(*limit); err != nil {
+				return fmt.Errorf("failed to load profiles: %w", err)
+			}
+		}
+
+		if len(profiles) == 0 {
+			return fmt.Errorf("no stored profiles to preview against, run a search first")
+		}
+
+		picker := templates.NewPicker(cfg.Messaging.RandomSeed)
+		for _, profile := range profiles {
+			fmt.Printf("== %s (%s)\n", profile.Name, profile.URL)
+			for _, src := range templateSources(cfg) {
+				text := src.Text
+				if src.Spintax {
+					text = picker.Spin(text)
+				}
+				text = templates.Fill(text, src.Vars(profile))
+				fmt.Printf("\n[%s] %d chars\n%s\n", src.Location, len([]rune(text)), text)
+			}
+			fmt.Println()
+		}
+		return nil
+
+	case "lint":
+		profiles, err := db.GetProfiles(0)
+		if err != nil {
+			return fmt.Errorf("failed to load profiles: %w", err)
+		}
+
+		issues := templates.Lint(templateSources(cfg), profiles, *threshold)
+		errors := 0
+		for _, issue := range issues {
+			fmt.Println(issue)
+			if issue.Severity == templates.SeverityError {
+				errors++
+			}
+		}
+
+		fmt.Printf("%d issue(s), %d error(s), checked against %d profile(s)\n", len(issues), errors, len(profiles))
+		if errors > 0 {
+			return fmt.Errorf("template lint failed")
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown templates subcommand %q", args[0])
+	}
+}
+
+// templateSources lists every configured note and message template
+func templateSources(cfg *config.Config) []templates.Source {
+	sources := []templates.Source{{
+		Location:  "connections.default_note",
+		Text:      cfg.Connections.DefaultNote,
+		Vars:      connection.NoteVars,
+		MaxLength: templates.NoteLimit,
+	}}
+
+	for i, t := range cfg.Messaging.MessageTemplates {
+		sources = append(sources, templates.Source{
+			Location: fmt.Sprintf("messaging.message_templates[%d]", i),
+			Text:     t.Text,
+			Vars:     messaging.MessageVars,
+			Spintax:  true,
+		})
+	}
+
+	for i, step := range cfg.Messaging.Sequence {
+		for j, t := range step.Templates {
+			sources = append(sources, templates.Source{
+				Location: fmt.Sprintf("messaging.sequence[%d].templates[%d]", i, j),
+				Text:     t.Text,
+				Vars:     messaging.MessageVars,
+				Spintax:  true,
+			})
+		}
+	}
+
+	return sources
+}
+
+// runReview handles "review", "review list", "review approve", "review
+// reject" and "review edit". Approved drafts are sent on the next run.
+func runReview(db *database.DB, args []string) error {
+	if len(args) == 0 {
+		return reviewInteractive(db)
+	}
+
+	fs := flag.NewFlagSet("review "+args[0], flag.ContinueOnError)
+	status := fs.String("status", database.ApprovalPending, "Status to list: pending, approved, rejected, sent, or empty for all")
+	body := fs.String("body", "", "Replacement text for the draft")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		actions, err := db.GetPendingActions(*status)
+		if err != nil {
+			return fmt.Errorf("failed to list drafts: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tACTION\tSTEP\tPROFILE\tSTATUS\tCREATED\tTEXT")
+		for _, a := range actions {
+			fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\t%s\t%s\n", a.ID, a.Action, a.SequenceStep, a.ProfileURL,
+				a.Status, a.CreatedAt.Local().Format(time.DateTime), truncate(a.Body, 60))
+		}
+		return w.Flush()
+
+	case "approve", "reject":
+		if fs.NArg() == 0 {
+			return fmt.Errorf("usage: review %s id...", args[0])
+		}
+
+		decision := database.ApprovalApproved
+		if args[0] == "reject" {
+			decision = database.ApprovalRejected
+		}
+
+		for _, arg := range fs.Args() {
+			id, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid id %q", arg)
+			}
+			if err := reviewDraft(db, id, decision, ""); err != nil {
+				return err
+			}
+			fmt.Printf("Draft %d %s\n", id, decision)
+		}
+		return nil
+
+	case "edit":
+		if fs.NArg() != 1 || *body == "" {
+			return fmt.Errorf("usage: review edit -body text id")
+		}
+
+		id, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid id %q", fs.Arg(0))
+		}
+		if err := reviewDraft(db, id, database.ApprovalApproved, *body); err != nil {
+			return err
+		}
+		fmt.Printf("Draft %d edited and approved\n", id)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown review subcommand %q", args[0])
+	}
+}
+
+// reviewInteractive walks through pending drafts and asks for a decision on each
+func reviewInteractive(db *database.DB) error {
+	actions, err := db.GetPendingActions(database.ApprovalPending)
+	if err != nil {
+		return fmt.Errorf("failed to list drafts: %w", err)
+	}
+
+	if len(actions) == 0 {
+		fmt.Println("No drafts waiting for approval")
+		return nil
+	}
+
+	in := bufio.NewScanner(os.Stdin)
+	prompt := func(text string) (string, bool) {
+		fmt.Print(text)
+		if !in.Scan() {
+			return "", false
+		}
+		return strings.TrimSpace(in.Text()), true
+	}
+
+	for n, a := range actions {
+		fmt.Printf("\n[%d/%d] #%d %s to %s (step %d)\n\n%s\n\n", n+1, len(actions), a.ID, a.Action,
+			a.ProfileURL, a.SequenceStep, a.Body)
+
+		for {
+			answer, ok := prompt("[a]pprove, [e]dit, [r]eject, [s]kip, [q]uit: ")
+			if !ok {
+				return in.Err()
+			}
+
+			var decision, body string
+			switch strings.ToLower(answer) {
+			case "a":
+				decision = database.ApprovalApproved
+			case "r":
+				decision = database.ApprovalRejected
+			case "e":
+				if body, ok = prompt("New text (one line): "); !ok {
+					return in.Err()
+				}
+				if body == "" {
+					continue
+				}
+				decision = database.ApprovalApproved
+			case "s":
+			case "q":
+				return nil
+			default:
+				continue
+			}
+
+			if decision != "" {
+				if err := reviewDraft(db, a.ID, decision, body); err != nil {
+					fmt.Println(err)
+					continue
+				}
+				fmt.Printf("Draft %d %s\n", a.ID, decision)
+			}
+			break
+		}
+	}
+
+	return nil
+}
+
+// reviewDraft records a decision on one draft, checking edited connection
+// notes against LinkedIn's length limit
+func reviewDraft(db *database.DB, id int64, decision, body string) error {
+	draft, err := db.GetPendingActionByID(id)
+	if err != nil {
+		return fmt.Errorf("failed to load draft %d: %w", id, err)
+	}
+	if draft == nil {
+		return fmt.Errorf("draft %d not found", id)
+	}
+
+	if draft.Action == retry.ActionConnect && len([]rune(body)) > templates.NoteLimit {
+		return fmt.Errorf("note is %d characters, over the %d character limit", len([]rune(body)), templates.NoteLimit)
+	}
+
+	ok, err := db.ReviewPendingAction(id, decision, body)
+	if err != nil {
+		return fmt.Errorf("failed to update draft %d: %w", id, err)
+	}
+	if !ok {
+		return fmt.Errorf("draft %d was already sent", id)
+	}
+	return nil
+}
+
+// truncate shortens text to n runes for table output
+func truncate(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if r := []rune(text); len(r) > n {
+		return string(r[:n-3]) + "..."
+	}
+	return text
+}
+
+// runSearchCommand handles one-off searches, "search -resume", "search
+// history", "search company" and the saved search subcommands "search save",
+// "search list", "search run" and "search delete"
+func runSearchCommand(cfg *config.Config, db *database.DB, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runOneOffSearch(cfg, db, args)
+	}
+	switch args[0] {
+	case "company":
+		return runCompanySearch(cfg, db, args[1:])
+	case "history":
+		return runSearchHistory(db, args[1:])
+	}
+
+	fs := flag.NewFlagSet("search "+args[0], flag.ContinueOnError)
+	name := fs.String("name", "", "Name of the saved search")
+	every := fs.Duration("every", 24*time.Hour, "How often the search is due when run with -due")
+	due := fs.Bool("due", false, "Run every saved search whose interval has elapsed")
+	params := searchFlags(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "save":
+		if *name == "" {
+			return fmt.Errorf("usage: search save -name n [-every d] [filters]")
+		}
+
+		p, err := params()
+		if err != nil {
+			return err
+		}
+		if err := search.SaveSearch(db, *name, p, *every); err != nil {
+			return fmt.Errorf("failed to save search: %w", err)
+		}
+		fmt.Printf("Saved search %q\n", *name)
+		return nil
+
+	case "list":
+		searches, err := db.GetSavedSearches()
+		if err != nil {
+			return fmt.Errorf("failed to list saved searches: %w", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tEVERY\tLAST RUN\tPARAMETERS")
+		for _, ss := range searches {
+			lastRun := "never"
+			if ss.LastRunAt != nil {
+				lastRun = ss.LastRunAt.Local().Format(time.DateTime)
+			}
+			interval := time.Duration(ss.IntervalMinutes) * time.Minute
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", ss.Name, interval, lastRun, ss.Params)
+		}
+		return w.Flush()
+
+	case "run":
+		var searches []*database.SavedSearch
+		if *due {
+			var err error
+			if searches, err = db.GetDueSavedSearches(); err != nil {
+				return fmt.Errorf("failed to list due searches: %w", err)
+			}
+		}
+		for _, n := range fs.Args() {
+			ss, err := db.GetSavedSearch(n)
+			if err != nil {
+				return fmt.Errorf("failed to load saved search %q: %w", n, err)
+			}
+			if ss == nil {
+				return fmt.Errorf("saved search %q not found", n)
+			}
+			searches = append(searches, ss)
+		}
+
+		if len(searches) == 0 {
+			if !*due {
+				return fmt.Errorf("usage: search run [-due] [name...]")
+			}
+			fmt.Println("No saved searches are due")
+			return nil
+		}
+
+		authInstance, err := startSession(cfg)
+		if err != nil {
+			return fmt.Errorf("login failed: %w", err)
+		}
+		defer authInstance.Close()
+
+		s := search.NewSearch(cfg, authInstance.GetPage(), authInstance.GetStealth(), db)
+		failed := 0
+		for _, ss := range searches {
+			summary, err := s.RunSavedSearch(ss)
+			if err != nil {
+				logger.Warn("Saved search failed",
+					"name", ss.Name,
+					"error", err,
+				)
+				failed++
+				continue
+			}
+
+			if err := printNewProfiles(db, ss.Name, summary); err != nil {
+				return err
+			}
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d of %d saved search(es) failed", failed, len(searches))
+		}
+		return nil
+
+	case "delete":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: search delete name")
+		}
+
+		ok, err := db.DeleteSavedSearch(fs.Arg(0))
+		if err != nil {
+			return fmt.Errorf("failed to delete saved search: %w", err)
+		}
+		if !ok {
+			return fmt.Errorf("saved search %q not found", fs.Arg(0))
+		}
+		fmt.Printf("Deleted saved search %q\n", fs.Arg(0))
+		return nil
+
+	default:
+		return fmt.Errorf("unknown search subcommand %q", args[0])
+	}
+}
+
+// runOneOffSearch runs a search given by filter flags, or with -resume
+// continues an unfinished run from the page after its checkpoint
+func runOneOffSearch(cfg *config.Config, db *database.DB, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	resume := fs.Bool("resume", false, "Continue the latest unfinished search from its last completed page")
+	runID := fs.Int64("run", 0, "Search run to resume instead of the latest unfinished one")
+	params := searchFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var run *database.SearchRun
+	var p search.SearchParams
+	if *resume {
+		var err error
+		if *runID != 0 {
+			run, err = db.GetSearchRun(*runID)
+		} else {
+			run, err = db.GetLatestUnfinishedSearchRun()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load search run: %w", err)
+		}
+		if run == nil {
+			fmt.Println("No unfinished search to resume")
+			return nil
+		}
+		fmt.Printf("Resuming search run %d from page %d\n", run.ID, run.LastPage+1)
+	} else {
+		var err error
+		if p, err = params(); err != nil {
+			return err
+		}
+		if p.JobTitle == "" && p.Keywords == "" && len(p.Locations) == 0 && len(p.CurrentCompanies) == 0 &&
+			len(p.PastCompanies) == 0 && len(p.Industries) == 0 && len(p.Schools) == 0 &&
+			len(p.ConnectionDegrees) == 0 && len(p.ProfileLanguages) == 0 && len(p.ServiceCategories) == 0 {
+			return fmt.Errorf("usage: search [-resume [-run id]] [filters]; give at least one filter")
+		}
+	}
+
+	authInstance, err := startSession(cfg)
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+	defer authInstance.Close()
+
+	s := search.NewSearch(cfg, authInstance.GetPage(), authInstance.GetStealth(), db)
+
+	if run != nil {
+		summary, err := s.ResumeSearch(run)
+		if err != nil {
+			return err
+		}
+		state := "completed"
+		if summary.Limited {
+			state = "reached max_results"
+		}
+		fmt.Printf("Search run %d %s: %d page(s), %d profile(s) seen, %d new\n",
+			summary.RunID, state, summary.Pages, summary.ProfilesSeen, summary.ProfilesNew)
+		return nil
+	}
+
+	profiles, err := s.SearchProfiles(p)
+	fmt.Printf("Found %d new profile(s)\n", len(profiles))
+	return err
+}
+
+// runSearchHistory handles "search history": it lists recent runs with what
+// each one visited and found, or with -by-query totals per set of parameters
+func runSearchHistory(db *database.DB, args []string) error {
+	fs := flag.NewFlagSet("search history", flag.ContinueOnError)
+	name := fs.String("name", "", "Only show runs of this saved search")
+	limit := fs.Int("limit", 20, "Maximum number of runs to show, 0 for all")
+	byQuery := fs.Bool("by-query", false, "Sum runs with the same parameters instead of listing them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+
+	if *byQuery {
+		stats, err := db.GetSearchQueryStats()
+		if err != nil {
+			return fmt.Errorf("failed to summarise search runs: %w", err)
+		}
+
+		fmt.Fprintln(w, "RUNS\tPAGES\tSEEN\tNEW\tDUPES\tNEW RATE\tLAST RUN\tPARAMETERS")
+		for _, st := range stats {
+			rate := "-"
+			if st.ProfilesSeen > 0 {
+				rate = fmt.Sprintf("%.0f%%", 100*float64(st.ProfilesNew)/float64(st.ProfilesSeen))
+			}
+			fmt.Fprintf(w, "%d\t%d\t%d\t%d\t%d\t%s\t%s\t%s\n", st.Runs, st.Pages, st.ProfilesSeen, st.ProfilesNew,
+				st.Duplicates, rate, st.LastRunAt.Local().Format(time.DateTime), st.Params)
+		}
+		return w.Flush()
+	}
+
+	searches, err := db.GetSavedSearches()
+	if err != nil {
+		return fmt.Errorf("failed to list saved searches: %w", err)
+	}
+	names := make(map[int64]string, len(searches))
+	var savedID int64
+	for _, ss := range searches {
+		names[ss.ID] = ss.Name
+		if ss.Name == *name {
+			savedID = ss.ID
+		}
+	}
+	if *name != "" && savedID == 0 {
+		return fmt.Errorf("saved search %q not found", *name)
+	}
+
+	runs, err := db.GetSearchRuns(savedID, *limit)
+	if err != nil {
+		return fmt.Errorf("failed to list search runs: %w", err)
+	}
+
+	fmt.Fprintln(w, "ID\tSTARTED\tSTATUS\tSEARCH\tPAGES\tCARDS\tNEW\tDUPES\tFAILED\tDURATION\tPARAMETERS")
+	for _, run := range runs {
+		label := "ad-hoc"
+		if run.SavedSearchID != 0 {
+			label = names[run.SavedSearchID]
+			if label == "" {
+				label = fmt.Sprintf("deleted #%d", run.SavedSearchID)
+			}
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%s\t%s\n", run.ID,
+			run.StartedAt.Local().Format(time.DateTime), run.Status, label, run.Pages, run.CardsSeen,
+			run.ProfilesNew, run.Duplicates, run.ExtractionFailures, run.Duration.Round(time.Second), run.Params)
+		if run.SearchURL != "" {
+			fmt.Fprintf(w, "\t\t\t\t\t\t\t\t\t\t%s\n", run.SearchURL)
+		}
+		if run.Error != "" {
+			fmt.Fprintf(w, "\t\t\t\t\t\t\t\t\t\terror: %s\n", truncate(run.Error, 120))
+		}
+	}
+	return w.Flush()
+}
+
+// runCompanySearch handles "search company": it resolves the company, searches
+// for its employees and lists everyone linked to it
+func runCompanySearch(cfg *config.Config, db *database.DB, args []string) error {
+	fs := flag.NewFlagSet("search company", flag.ContinueOnError)
+	titles := &listFlag{}
+	fs.Var(titles, "title", "Job title keyword to limit employees to (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: search company [-title t]... <company-url-or-name>")
+	}
+	target := strings.Join(fs.Args(), " ")
+
+	authInstance, err := startSession(cfg)
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+	defer authInstance.Close()
+
+	s := search.NewSearch(cfg, authInstance.GetPage(), authInstance.GetStealth(), db)
+
+	company, found, searchErr := s.SearchCompany(target, titles.values)
+	if company == nil {
+		return searchErr
+	}
+
+	profiles, err := db.GetCompanyProfiles(company.ID)
+	if err != nil {
+		return fmt.Errorf("failed to load company profiles: %w", err)
+	}
+
+	fmt.Printf("%s (%s): %d new profile(s), %d linked in total\n", company.Name, company.URL, len(found), len(profiles))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "  NAME\tHEADLINE\tLOCATION\tPROFILE")
+	for _, p := range profiles {
+		if !matchesAny(p.Headline, titles.values) {
+			continue
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", p.Name, truncate(p.Headline, 50), p.Location, p.URL)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	return searchErr
+}
+
+// matchesAny reports whether text contains any of keywords, ignoring case.
+// No keywords matches everything.
+func matchesAny(text string, keywords []string) bool {
+	if len(keywords) == 0 {
+		return true
+	}
+	text = strings.ToLower(text)
+	for _, k := range keywords {
+		if strings.Contains(text, strings.ToLower(k)) {
+			return true
+		}
+	}
+	return false
+}
+
+// printNewProfiles reports the outcome of a saved search run and lists the
+// profiles it found for the first time
+func printNewProfiles(db *database.DB, name string, summary *search.RunSummary) error {
+	fmt.Printf("%s: %d page(s), %d profile(s), %d new", name, summary.Pages, summary.ProfilesSeen, summary.ProfilesNew)
+	if summary.StoppedEarly {
+		fmt.Print(", stopped at a page of known profiles")
+	}
+	if summary.Limited {
+		fmt.Printf(", reached max_results; continue with \"search -resume -run %d\"", summary.RunID)
+	}
+	fmt.Println()
+
+	if summary.ProfilesNew == 0 {
+		return nil
+	}
+
+	profiles, err := db.GetNewSearchResults(summary.RunID)
+	if err != nil {
+		return fmt.Errorf("failed to load new profiles: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "  NAME\tHEADLINE\tPROFILE")
+	for _, p := range profiles {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", p.Name, truncate(p.Headline, 50), p.URL)
+	}
+	return w.Flush()
+}
+
+// listFlag collects a flag that may be given several times. With split set,
+// each value may also hold a comma-separated list.
+type listFlag struct {
+	values []string
+	split  bool
+}
+
+func (l *listFlag) String() string { return strings.Join(l.values, ",") }
+
+func (l *listFlag) Set(value string) error {
+	parts := []string{value}
+	if l.split {
+		parts = strings.Split(value, ",")
+	}
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			l.values = append(l.values, p)
+		}
+	}
+	return nil
+}
+
+// searchFlags registers the search filter flags on fs and returns a function
+// that builds the parameters once fs has been parsed
+func searchFlags(fs *flag.FlagSet) func() (search.SearchParams, error) {
+	title := fs.String("title", "", "Job title")
+	keywords := fs.String("keywords", "", `Boolean keyword query, e.g. 'golang AND (kubernetes OR k8s) NOT "project manager"'`)
+	allWords := &listFlag{}
+	anyWords := &listFlag{}
+	noneWords := &listFlag{}
+	locations := &listFlag{}
+	companies := &listFlag{split: true}
+	pastCompanies := &listFlag{split: true}
+	industries := &listFlag{split: true}
+	schools := &listFlag{split: true}
+	degrees := &listFlag{split: true}
+	languages := &listFlag{split: true}
+	services := &listFlag{split: true}
+
+	fs.Var(allWords, "all", "Word or phrase every result must contain (repeatable)")
+	fs.Var(anyWords, "any", "Word or phrase of which results need at least one (repeatable)")
+	fs.Var(noneWords, "none", "Word or phrase results must not contain (repeatable)")
+	fs.Var(locations, "location", "Location name or geo URN (repeatable)")
+	fs.Var(companies, "company", "Current company ids (repeatable, comma-separated)")
+	fs.Var(pastCompanies, "past-company", "Past company ids (repeatable, comma-separated)")
+	fs.Var(industries, "industry", "Industry ids (repeatable, comma-separated)")
+	fs.Var(schools, "school", "School ids (repeatable, comma-separated)")
+	fs.Var(degrees, "degree", "Connection degrees 1, 2 or 3 (comma-separated)")
+	fs.Var(languages, "language", "Profile language codes such as en (comma-separated)")
+	fs.Var(services, "service", "Service category ids (repeatable, comma-separated)")
+
+	return func() (search.SearchParams, error) {
+		keywordQuery, err := query.Parse(*keywords)
+		if err != nil {
+			return search.SearchParams{}, fmt.Errorf("invalid -keywords: %w", err)
+		}
+		var anyOf, noneOf []*query.Node
+		for _, v := range allWords.values {
+			keywordQuery = query.And(keywordQuery, query.Literal(v))
+		}
+		for _, v := range anyWords.values {
+			anyOf = append(anyOf, query.Literal(v))
+		}
+		for _, v := range noneWords.values {
+			noneOf = append(noneOf, query.Literal(v))
+		}
+		keywordQuery = query.And(keywordQuery, query.Or(anyOf...), query.Not(query.Or(noneOf...)))
+		if err := keywordQuery.Validate(); err != nil {
+			return search.SearchParams{}, fmt.Errorf("invalid keyword query: %w", err)
+		}
+
+		params := search.SearchParams{
+			JobTitle:          *title,
+			Keywords:          keywordQuery.String(),
+			Locations:         locations.values,
+			CurrentCompanies:  companies.values,
+			PastCompanies:     pastCompanies.values,
+			Industries:        industries.values,
+			Schools:           schools.values,
+			ProfileLanguages:  languages.values,
+			ServiceCategories: services.values,
+		}
+		for _, d := range degrees.values {
+			n, err := strconv.Atoi(d)
+			if err != nil || n < 1 || n > 3 {
+				return params, fmt.Errorf("invalid connection degree %q, expected 1, 2 or 3", d)
+			}
+			params.ConnectionDegrees = append(params.ConnectionDegrees, n)
+		}
+		return params, nil
+	}
+}
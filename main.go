@@ -3,17 +3,23 @@ package main
 import (
 	"flag"
 	"fmt"
+	"math"
+	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/go-rod/rod"
+	"linkedin-automation/pkg/artifacts"
 	"linkedin-automation/pkg/auth"
 	"linkedin-automation/pkg/config"
 	"linkedin-automation/pkg/connection"
 	"linkedin-automation/pkg/database"
 	"linkedin-automation/pkg/logger"
 	"linkedin-automation/pkg/messaging"
+	"linkedin-automation/pkg/metrics"
 	"linkedin-automation/pkg/search"
+	"linkedin-automation/pkg/selectors"
 	"linkedin-automation/pkg/stealth"
 )
 
@@ -21,6 +27,8 @@ func main() {
 	// Parse command line flags
 	configPath := flag.String("config", "config/config.yaml", "Path to configuration file")
 	mode := flag.String("mode", "search", "Operation mode: search, connect, message, or all")
+	campaign := flag.String("campaign", "", "Campaign name added to every log line, overriding logging.campaign")
+	flag.Usage = usage
 	flag.Parse()
 
 	// Load configuration
@@ -31,45 +39,99 @@ func main() {
 	}
 
 	// Initialize logger
-	log, err := logger.NewLogger(cfg.Logging.Level, cfg.Logging.Format, cfg.Logging.Output)
+	if *campaign != "" {
+		cfg.Logging.Campaign = *campaign
+	}
+	var sinks []logger.Sink
+	for _, sink := range cfg.Logging.Sinks {
+		sinks = append(sinks, logger.Sink{
+			Output:     sink.Output,
+			Format:     sink.Format,
+			Level:      sink.Level,
+			MaxSizeMB:  sink.MaxSizeMB,
+			MaxAge:     time.Duration(sink.MaxAgeHours) * time.Hour,
+			MaxBackups: sink.MaxBackups,
+			Compress:   sink.Compress,
+		})
+	}
+	log, err := logger.Init(logger.Options{
+		Level:      cfg.Logging.Level,
+		Format:     cfg.Logging.Format,
+		Output:     cfg.Logging.Output,
+		Sinks:      sinks,
+		Components: cfg.Logging.Components,
+		Campaign:   cfg.Logging.Campaign,
+	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
 		os.Exit(1)
 	}
 	defer log.Close()
 
-	logger.Info("LinkedIn Automation Tool Started", map[string]interface{}{
-		"mode": *mode,
-	})
+	// Save a screenshot, the HTML and the URL of pages where something fails
+	if cfg.Artifacts.Enabled {
+		dir := cfg.Artifacts.Dir
+		if dir == "" {
+			dir = "data/debug"
+		}
+		artifacts.Init(dir, log.RunID(), cfg.Artifacts.MaxPerRun)
+	}
+
+	// Load the selectors used to find elements on LinkedIn's pages
+	if _, err := selectors.Load(cfg.Browser.Selectors); err != nil {
+		logger.Error("Failed to load selectors", "error", err)
+		os.Exit(1)
+	}
+
+	logger.Info("LinkedIn Automation Tool Started",
+		"mode", *mode,
+	)
 
 	// Ensure data directory exists
 	dbDir := filepath.Dir(cfg.Database.Path)
 	if err := os.MkdirAll(dbDir, 0755); err != nil {
-		logger.Error("Failed to create data directory", map[string]interface{}{"error": err.Error()})
+		logger.Error("Failed to create data directory", "error", err)
 		os.Exit(1)
 	}
 
 	// Initialize database
 	db, err := database.NewDB(cfg.Database.Path)
 	if err != nil {
-		logger.Error("Failed to initialize database", map[string]interface{}{"error": err.Error()})
+		logger.Error("Failed to initialize database", "error", err)
 		os.Exit(1)
 	}
 	defer db.Close()
 
-	// Initialize authentication
-	authInstance, err := auth.NewAuth(cfg)
-	if err != nil {
-		logger.Error("Failed to initialize authentication", map[string]interface{}{"error": err.Error()})
-		os.Exit(1)
+	// Expose metrics for the length of the run
+	if cfg.Metrics.Enabled {
+		srv, err := startMetrics(cfg, db)
+		if err != nil {
+			logger.Error("Failed to start metrics server", "error", err)
+			os.Exit(1)
+		}
+		defer srv.Close()
 	}
-	defer authInstance.Close()
 
-	// Perform login
-	if err := authInstance.Login(); err != nil {
-		logger.Error("Login failed", map[string]interface{}{"error": err.Error()})
+	// Subcommands such as "invitations inbound" take precedence over -mode
+	if flag.NArg() > 0 {
+		if err := runCommand(cfg, db, flag.Args()); err != nil {
+			logger.Error("Command failed",
+				"command", flag.Arg(0),
+				"error", err,
+			)
+			os.Exit(1)
+		}
+		logger.Info("LinkedIn Automation Tool Completed")
+		return
+	}
+
+	// Initialize authentication and log in
+	authInstance, err := startSession(cfg)
+	if err != nil {
+		logger.Error("Login failed", "error", err)
 		os.Exit(1)
 	}
+	defer authInstance.Close()
 
 	// Get authenticated page and stealth instance
 	page := authInstance.GetPage()
@@ -77,7 +139,7 @@ func main() {
 
 	// Check if we should operate based on scheduling
 	if !stealthInstance.ShouldOperate() {
-		logger.Info("Outside business hours, waiting...", nil)
+		logger.Info("Outside business hours, waiting...")
 		// In a real implementation, you'd wait until business hours
 	}
 
@@ -85,46 +147,105 @@ func main() {
 	switch *mode {
 	case "search":
 		if err := runSearch(cfg, page, stealthInstance, db); err != nil {
-			logger.Error("Search failed", map[string]interface{}{"error": err.Error()})
+			logger.Error("Search failed", "error", err)
 			os.Exit(1)
 		}
 
 	case "connect":
 		if err := runConnect(cfg, page, stealthInstance, db); err != nil {
-			logger.Error("Connection failed", map[string]interface{}{"error": err.Error()})
+			logger.Error("Connection failed", "error", err)
 			os.Exit(1)
 		}
 
 	case "message":
 		if err := runMessage(cfg, page, stealthInstance, db); err != nil {
-			logger.Error("Messaging failed", map[string]interface{}{"error": err.Error()})
+			logger.Error("Messaging failed", "error", err)
 			os.Exit(1)
 		}
 
 	case "all":
 		// Run all operations in sequence
 		if err := runSearch(cfg, page, stealthInstance, db); err != nil {
-			logger.Warn("Search failed, continuing", map[string]interface{}{"error": err.Error()})
+			logger.Warn("Search failed, continuing", "error", err)
 		}
 
 		stealthInstance.RandomBreak()
 
 		if err := runConnect(cfg, page, stealthInstance, db); err != nil {
-			logger.Warn("Connection failed, continuing", map[string]interface{}{"error": err.Error()})
+			logger.Warn("Connection failed, continuing", "error", err)
 		}
 
 		stealthInstance.RandomBreak()
 
 		if err := runMessage(cfg, page, stealthInstance, db); err != nil {
-			logger.Warn("Messaging failed, continuing", map[string]interface{}{"error": err.Error()})
+			logger.Warn("Messaging failed, continuing", "error", err)
 		}
 
 	default:
-		logger.Error("Invalid mode", map[string]interface{}{"mode": *mode})
+		logger.Error("Invalid mode", "mode", *mode)
 		os.Exit(1)
 	}
 
-	logger.Info("LinkedIn Automation Tool Completed", nil)
+	logger.Info("LinkedIn Automation Tool Completed")
+}
+
+// startSession launches the browser and logs in to LinkedIn. The caller must
+// Close the returned Auth.
+func startSession(cfg *config.Config) (*auth.Auth, error) {
+	authInstance, err := auth.NewAuth(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize authentication: %w", err)
+	}
+
+	if err := authInstance.Login(); err != nil {
+		authInstance.Close()
+		return nil, err
+	}
+
+	return authInstance, nil
+}
+
+// startMetrics serves /metrics, reading the daily quota and queue depths
+// from the database on every scrape
+func startMetrics(cfg *config.Config, db *database.DB) (*http.Server, error) {
+	campaign := cfg.Logging.Campaign
+	if campaign == "" {
+		campaign = logger.DefaultCampaign
+	}
+	metrics.Init(campaign)
+
+	metrics.Default.OnCollect(func() {
+		if sent, err := db.CountConnectionsSentToday(); err == nil {
+			metrics.DailyQuotaRemaining.Set(float64(max(cfg.Connections.DailyLimit-sent, 0)), "connect")
+		} else {
+			logger.Debug("Failed to read daily connection count", "error", err)
+		}
+
+		if stats, err := db.GetDailyStats(time.Now()); err == nil {
+			remaining := math.Inf(1) // no limit
+			if cfg.Messaging.DailyLimit > 0 {
+				remaining = float64(max(cfg.Messaging.DailyLimit-stats.MessagesSent, 0))
+			}
+			metrics.DailyQuotaRemaining.Set(remaining, "message")
+		} else {
+			logger.Debug("Failed to read daily message count", "error", err)
+		}
+
+		depths, err := db.GetQueueDepths()
+		if err != nil {
+			logger.Debug("Failed to read queue depths", "error", err)
+			return
+		}
+		for queue, depth := range depths {
+			metrics.QueueDepth.Set(float64(depth), queue)
+		}
+	})
+
+	listen := cfg.Metrics.Listen
+	if listen == "" {
+		listen = "127.0.0.1:9464"
+	}
+	return metrics.Serve(listen)
 }
 
 // runSearch executes search operations
@@ -137,15 +258,18 @@ func runSearch(cfg *config.Config, page *rod.Page, stealthInstance *stealth.Stea
 		Location: "San Francisco",
 		Keywords: "Python Go",
 	}
+	if !cfg.Search.Keywords.IsZero() {
+		params.Keywords = cfg.Search.Keywords.String()
+	}
 
 	profiles, err := searchInstance.SearchProfiles(params)
 	if err != nil {
 		return fmt.Errorf("search failed: %w", err)
 	}
 
-	logger.Info("Search completed", map[string]interface{}{
-		"profiles_found": len(profiles),
-	})
+	logger.Info("Search completed",
+		"profiles_found", len(profiles),
+	)
 
 	return nil
 }
@@ -154,11 +278,13 @@ func runSearch(cfg *config.Config, page *rod.Page, stealthInstance *stealth.Stea
 func runConnect(cfg *config.Config, page *rod.Page, stealthInstance *stealth.Stealth, db *database.DB) error {
 	connInstance := connection.NewConnection(cfg, page, stealthInstance, db)
 
-	// Get profiles from database that haven't been connected
-	// In a real implementation, you'd query the database for profiles without connection requests
-	// For now, this is a placeholder
+	// Send requests to profiles that haven't been contacted, including failed
+	// attempts whose backoff has elapsed
+	if err := connInstance.SendConnectionRequests(); err != nil {
+		return fmt.Errorf("connection requests failed: %w", err)
+	}
 
-	logger.Info("Connection operations completed", nil)
+	logger.Info("Connection operations completed")
 	return nil
 }
 
@@ -171,7 +297,7 @@ func runMessage(cfg *config.Config, page *rod.Page, stealthInstance *stealth.Ste
 		return fmt.Errorf("follow-up messages failed: %w", err)
 	}
 
-	logger.Info("Messaging operations completed", nil)
+	logger.Info("Messaging operations completed")
 	return nil
 }
 
@@ -0,0 +1,112 @@
+package approval
+
+import (
+	"strings"
+
+	"linkedin-automation/pkg/config"
+	"linkedin-automation/pkg/database"
+	"linkedin-automation/pkg/logger"
+)
+
+var log = logger.Component("approval")
+
+// Gate holds outgoing notes or messages of one action type for human review
+type Gate struct {
+	db        *database.DB
+	enabled   bool
+	companies []string
+	action    string
+}
+
+// NewGate creates a gate for the given action type
+func NewGate(cfg *config.Config, db *database.DB, action string) *Gate {
+	g := &Gate{
+		db:      db,
+		enabled: cfg.Approval.Enabled,
+		action:  action,
+	}
+
+	for _, company := range cfg.Approval.Companies {
+		if company = strings.ToLower(strings.TrimSpace(company)); company != "" {
+			g.companies = append(g.companies, company)
+		}
+	}
+
+	return g
+}
+
+// Required reports whether sends to the profile need approval. Profiles that
+// are not stored are held back when a company filter is configured, since
+// their company cannot be checked.
+func (g *Gate) Required(profileURL string) bool {
+	if !g.enabled {
+		return false
+	}
+	if len(g.companies) == 0 {
+		return true
+	}
+
+	profile, err := g.db.GetProfileByURL(profileURL)
+	if err != nil || profile == nil {
+		return true
+	}
+
+	company := strings.ToLower(profile.Company)
+	for _, c := range g.companies {
+		if strings.Contains(company, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// Draft returns the draft for the profile and step, writing one from compose
+// on first sight. created is true when the draft was just written. Callers
+// send only drafts whose status is approved.
+func (g *Gate) Draft(profileURL string, step int, compose func() (string, int)) (draft *database.PendingAction, created bool, err error) {
+	draft, err = g.db.GetPendingAction(g.action, profileURL, step)
+	if err != nil || draft != nil {
+		return draft, false, err
+	}
+
+	body, templateIndex := compose()
+	draft = &database.PendingAction{
+		Action:        g.action,
+		ProfileURL:    profileURL,
+		SequenceStep:  step,
+		TemplateIndex: templateIndex,
+		Body:          body,
+	}
+	if profile, _ := g.db.GetProfileByURL(profileURL); profile != nil {
+		draft.ProfileID = profile.ID
+	}
+
+	if err := g.db.AddPendingAction(draft); err != nil {
+		return nil, false, err
+	}
+
+	log.Info("Draft queued for approval",
+		"action", g.action,
+		"profile_url", profileURL,
+		"sequence_step", step,
+	)
+
+	draft, err = g.db.GetPendingAction(g.action, profileURL, step)
+	return draft, true, err
+}
+
+// Sent marks an approved draft as sent. It does nothing for a nil draft, so
+// callers can pass the result of sends that did not need approval.
+func (g *Gate) Sent(draft *database.PendingAction) {
+	if draft == nil {
+		return
+	}
+
+	if err := g.db.MarkPendingActionSent(draft.ID); err != nil {
+		log.Warn("Failed to mark draft as sent",
+			"action", g.action,
+			"profile_url", draft.ProfileURL,
+			"error", err,
+		)
+	}
+}
@@ -0,0 +1,127 @@
+package artifacts
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"linkedin-automation/pkg/logger"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+var log = logger.Component("artifacts")
+
+// Key is the log attribute holding the directory of a failure's artefacts
+const Key = "artifacts"
+
+// captureTimeout bounds the time spent saving artefacts, so a hung page
+// does not hold up the run it is reporting on
+const captureTimeout = 20 * time.Second
+
+var (
+	mu       sync.Mutex
+	dir      string // empty until Init, which disables capturing
+	maxCount int
+	count    int
+)
+
+// Init enables capturing into a directory for this run under root. At most
+// max failures are captured, so a broken selector failing on every profile
+// cannot fill the disk; 0 means no limit.
+func Init(root, runID string, max int) {
+	mu.Lock()
+	defer mu.Unlock()
+	dir = filepath.Join(root, runID)
+	maxCount = max
+	count = 0
+}
+
+// Capture saves a full-page screenshot, the page HTML, the current URL and
+// the error to a new timestamped directory, and returns an attribute naming
+// it for the log line that reports the failure:
+//
+//	log.Warn("Failed to send connection request", "error", err, artifacts.Capture(page, "connect", err))
+//
+// When capturing is disabled, the limit has been reached or nothing could be
+// saved, the attribute is empty and log handlers leave it out.
+func Capture(page *rod.Page, action string, cause error) slog.Attr {
+	if page == nil {
+		return slog.Attr{}
+	}
+
+	mu.Lock()
+	if dir == "" || (maxCount > 0 && count >= maxCount) {
+		mu.Unlock()
+		return slog.Attr{}
+	}
+	count++
+	n, limit, root := count, maxCount, dir
+	mu.Unlock()
+
+	target := filepath.Join(root, fmt.Sprintf("%s-%03d-%s", time.Now().Format("20060102T150405.000"), n, action))
+	if err := save(page.Timeout(captureTimeout), target, cause); err != nil {
+		log.Warn("Failed to save failure artefacts",
+			"dir", target,
+			"error", err,
+		)
+		if _, statErr := os.Stat(target); statErr != nil {
+			return slog.Attr{}
+		}
+	}
+
+	if limit > 0 && n == limit {
+		log.Warn("Failure artefact limit reached, no more will be saved this run",
+			"limit", limit,
+		)
+	}
+
+	return slog.String(Key, target)
+}
+
+// save writes what it can: a page that no longer responds may still yield
+// its URL, so each file is attempted and the first error is returned
+func save(page *rod.Page, target string, cause error) error {
+	// Page HTML may hold personal details, like the logs
+	if err := os.MkdirAll(target, 0700); err != nil {
+		return fmt.Errorf("failed to create artefact directory: %w", err)
+	}
+
+	var first error
+	keep := func(err error) {
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+
+	if cause != nil {
+		keep(os.WriteFile(filepath.Join(target, "error.txt"), []byte(cause.Error()+"\n"), 0600))
+	}
+
+	if info, err := page.Info(); err == nil {
+		keep(os.WriteFile(filepath.Join(target, "url.txt"), []byte(info.URL+"\n"), 0600))
+	} else {
+		keep(fmt.Errorf("failed to read page URL: %w", err))
+	}
+
+	if html, err := page.HTML(); err == nil {
+		keep(os.WriteFile(filepath.Join(target, "page.html"), []byte(html), 0600))
+	} else {
+		keep(fmt.Errorf("failed to read page HTML: %w", err))
+	}
+
+	shot, err := page.Screenshot(true, &proto.PageCaptureScreenshot{
+		Format: proto.PageCaptureScreenshotFormatPng,
+	})
+	if err == nil {
+		keep(os.WriteFile(filepath.Join(target, "screenshot.png"), shot, 0600))
+	} else {
+		keep(fmt.Errorf("failed to take screenshot: %w", err))
+	}
+
+	return first
+}
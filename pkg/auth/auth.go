@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"time"
 
+	"linkedin-automation/pkg/artifacts"
 	"linkedin-automation/pkg/config"
 	"linkedin-automation/pkg/logger"
+	"linkedin-automation/pkg/metrics"
+	"linkedin-automation/pkg/selectors"
 	stealthpkg "linkedin-automation/pkg/stealth"
 
 	"github.com/go-rod/rod"
@@ -13,6 +16,8 @@ import (
 	rodstealth "github.com/go-rod/stealth"
 )
 
+var log = logger.Component("auth")
+
 // Auth handles LinkedIn authentication
 type Auth struct {
 	cfg     *config.Config
@@ -59,27 +64,29 @@ func (a *Auth) Login() error {
 	a.page = page
 
 	// Navigate to LinkedIn login
+	loadStart := time.Now()
 	if err := page.Navigate(a.cfg.LinkedIn.BaseURL + "/login"); err != nil {
 		return fmt.Errorf("failed to navigate to login page: %w", err)
 	}
 
 	// Wait for page load
 	page.MustWaitLoad()
+	metrics.ObservePageLoad("login", loadStart)
 
 	// Check for existing session
 	if a.isLoggedIn() {
-		logger.Info("Already logged in", nil)
+		log.Info("Already logged in")
 		return nil
 	}
 
 	// Fill login form
 	if err := a.fillLoginForm(); err != nil {
-		return fmt.Errorf("failed to fill login form: %w", err)
+		return a.loginFailed(fmt.Errorf("failed to fill login form: %w", err))
 	}
 
 	// Wait for potential security checkpoints or feed
 	if a.hasSecurityCheckpoint() {
-		logger.Warn("Security checkpoint detected - manual intervention required", nil)
+		log.Warn("Security checkpoint detected - manual intervention required")
 		waitUntil := time.Now().Add(5 * time.Minute)
 		for time.Now().Before(waitUntil) {
 			time.Sleep(3 * time.Second)
@@ -88,7 +95,7 @@ func (a *Auth) Login() error {
 			}
 		}
 		if !a.isLoggedIn() {
-			return fmt.Errorf("login timeout or failed")
+			return a.loginFailed(fmt.Errorf("login timeout or failed"))
 		}
 	} else {
 		// Wait for successful login
@@ -100,14 +107,24 @@ func (a *Auth) Login() error {
 			time.Sleep(500 * time.Millisecond)
 		}
 		if !a.isLoggedIn() {
-			return fmt.Errorf("login failed")
+			return a.loginFailed(fmt.Errorf("login failed"))
 		}
 	}
 
-	logger.Info("Login successful", nil)
+	log.Info("Login successful")
 	return nil
 }
 
+// loginFailed logs a failed login with the login page's artefacts, which
+// show a changed form or an unexpected challenge, and returns err
+func (a *Auth) loginFailed(err error) error {
+	log.Warn("Login did not complete",
+		"error", err,
+		artifacts.Capture(a.page, "login", err),
+	)
+	return err
+}
+
 // GetPage returns the authenticated page
 func (a *Auth) GetPage() *rod.Page {
 	return a.page
@@ -131,24 +148,32 @@ func (a *Auth) isLoggedIn() bool {
 	currentURL := a.page.MustInfo().URL
 	return currentURL == a.cfg.LinkedIn.BaseURL+"/feed" ||
 		currentURL == a.cfg.LinkedIn.BaseURL+"/feed/" ||
-		a.page.MustHas("div[data-control-name=\"feed_out_of_network\"]") ||
-		a.page.MustHas("div[data-control-name=\"feed_reconnect\"]")
+		selectors.Has(a.page, "feed_indicator")
 }
 
 func (a *Auth) fillLoginForm() error {
 	// Wait for login form
-	a.page.MustElement("input[name=\"session_key\"]").MustWaitVisible()
+	emailEl, err := selectors.Wait(a.page, "login_email_input", time.Duration(a.cfg.Browser.Timeout)*time.Millisecond)
+	if err != nil {
+		return fmt.Errorf("failed to find login form: %w", err)
+	}
+	emailEl.MustWaitVisible()
 
 	// Type email with human-like behavior
-	emailEl := a.page.MustElement("input[name=\"session_key\"]")
 	a.stealth.HumanType(emailEl, a.cfg.LinkedIn.Email)
 
 	// Type password
-	passwordEl := a.page.MustElement("input[name=\"session_password\"]")
+	passwordEl, err := selectors.Find(a.page, "login_password_input")
+	if err != nil {
+		return fmt.Errorf("failed to find password input: %w", err)
+	}
 	a.stealth.HumanType(passwordEl, a.cfg.LinkedIn.Password)
 
 	// Click sign in button
-	signInBtn := a.page.MustElement("button[type=\"submit\"]")
+	signInBtn, err := selectors.Find(a.page, "login_submit_button")
+	if err != nil {
+		return fmt.Errorf("failed to find sign in button: %w", err)
+	}
 	a.stealth.HumanClick(signInBtn)
 
 	return nil
@@ -156,12 +181,12 @@ func (a *Auth) fillLoginForm() error {
 
 func (a *Auth) hasSecurityCheckpoint() bool {
 	// Check for 2FA input
-	if a.page.MustHas("input[name=\"pin\"]") {
+	if selectors.Has(a.page, "verification_pin_input") {
 		return true
 	}
 
 	// Check for captcha
-	if a.page.MustHas("#captcha-internal") || a.page.MustHas(".captcha") {
+	if selectors.Has(a.page, "captcha") {
 		return true
 	}
 
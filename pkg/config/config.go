@@ -5,6 +5,8 @@ import (
 	"os"
 	"strconv"
 
+	"linkedin-automation/pkg/query"
+
 	"github.com/joho/godotenv"
 	"gopkg.in/yaml.v3"
 )
@@ -16,15 +18,23 @@ type Config struct {
 	Search      SearchConfig     `yaml:"search"`
 	Connections ConnectionConfig `yaml:"connections"`
 	Messaging   MessagingConfig  `yaml:"messaging"`
+	Invitations InvitationConfig `yaml:"invitations"`
+	Engagement  EngagementConfig `yaml:"engagement"`
+	Retry       RetryConfig      `yaml:"retry"`
+	Approval    ApprovalConfig   `yaml:"approval"`
 	Stealth     StealthConfig    `yaml:"stealth"`
 	Database    DatabaseConfig   `yaml:"database"`
 	Logging     LoggingConfig    `yaml:"logging"`
+	Metrics     MetricsConfig    `yaml:"metrics"`
+	Artifacts   ArtifactsConfig  `yaml:"artifacts"`
 }
 
 type BrowserConfig struct {
 	Headless bool           `yaml:"headless"`
 	Timeout  int            `yaml:"timeout"`
 	Viewport ViewportConfig `yaml:"viewport"`
+	// Selectors is the path of the selectors file describing LinkedIn's markup
+	Selectors string `yaml:"selectors"`
 }
 
 type ViewportConfig struct {
@@ -42,6 +52,12 @@ type SearchConfig struct {
 	MaxResults      int `yaml:"max_results"`
 	ResultsPerPage  int `yaml:"results_per_page"`
 	PaginationDelay int `yaml:"pagination_delay"`
+	// Locations maps location names to geo URNs ("urn:li:geo:<id>" or just
+	// the id), taking precedence over the bundled table
+	Locations map[string]string `yaml:"locations"`
+	// Keywords is the boolean keyword query used by -mode search, given as a
+	// string or built from and/or/not/phrase mappings; it is validated on load
+	Keywords query.Node `yaml:"keywords"`
 }
 
 type ConnectionConfig struct {
@@ -52,9 +68,100 @@ type ConnectionConfig struct {
 }
 
 type MessagingConfig struct {
-	Enabled          bool     `yaml:"enabled"`
-	FollowUpDelay    int      `yaml:"follow_up_delay"`
-	MessageTemplates []string `yaml:"message_templates"`
+	Enabled          bool                 `yaml:"enabled"`
+	FollowUpDelay    int                  `yaml:"follow_up_delay"`
+	MessageTemplates []MessageTemplate    `yaml:"message_templates"`
+	Sequence         []SequenceStepConfig `yaml:"sequence"`
+	InboxSync        InboxSyncConfig      `yaml:"inbox_sync"`
+	ReplyRules       []ReplyRuleConfig    `yaml:"reply_rules"`
+	// RandomSeed makes template selection and spintax reproducible; 0 seeds from the clock
+	RandomSeed int64 `yaml:"random_seed"`
+	// DailyLimit caps the messages sent per day; 0 means no limit
+	DailyLimit int `yaml:"daily_limit"`
+}
+
+// MessageTemplate is a message text with a relative selection weight. In
+// YAML it may be given as a plain string, which has weight 1.
+type MessageTemplate struct {
+	Text   string `yaml:"text"`
+	Weight int    `yaml:"weight"`
+}
+
+// UnmarshalYAML accepts either a plain string or a {text, weight} mapping
+func (t *MessageTemplate) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		t.Text = value.Value
+		t.Weight = 1
+		return nil
+	}
+
+	type plain MessageTemplate
+	p := plain{Weight: 1}
+	if err := value.Decode(&p); err != nil {
+		return err
+	}
+	*t = MessageTemplate(p)
+	return nil
+}
+
+// InboxSyncConfig controls reading conversation threads from the inbox.
+// MaxThreads limits how many of the most recent threads are visited.
+type InboxSyncConfig struct {
+	Enabled    bool `yaml:"enabled"`
+	MaxThreads int  `yaml:"max_threads"`
+}
+
+// ReplyRuleConfig labels inbound replies. Keywords match whole words and
+// Patterns are regular expressions, both case-insensitive. Rules are tried in
+// order and the first match wins. A reply labelled "unsubscribe" puts the
+// profile on the do-not-contact list.
+type ReplyRuleConfig struct {
+	Label    string   `yaml:"label"` // interested, not_interested, out_of_office, referral, unsubscribe
+	Keywords []string `yaml:"keywords"`
+	Patterns []string `yaml:"patterns"`
+}
+
+// SequenceStepConfig is one message of the follow-up sequence. Delay is in
+// milliseconds, counted from acceptance for the first step and from the
+// previous step otherwise. Steps without templates use MessageTemplates.
+type SequenceStepConfig struct {
+	Delay     int               `yaml:"delay"`
+	Templates []MessageTemplate `yaml:"templates"`
+}
+
+type InvitationConfig struct {
+	Inbound InboundRulesConfig `yaml:"inbound"`
+}
+
+// InboundRulesConfig decides what happens to received invitations. Ignore
+// rules win over accept rules; anything unmatched is left for manual review.
+type InboundRulesConfig struct {
+	MaxInvitations         int      `yaml:"max_invitations"`
+	AcceptHeadlinePatterns []string `yaml:"accept_headline_patterns"`
+	IgnoreCompanies        []string `yaml:"ignore_companies"`
+}
+
+// EngagementConfig limits how many reactions and comments are read from
+// each post by the engagement collector; 0 reads all of them
+type EngagementConfig struct {
+	MaxReactions int `yaml:"max_reactions"`
+	MaxComments  int `yaml:"max_comments"`
+}
+
+// RetryConfig controls how failed connection requests and messages are
+// retried. Delays are in milliseconds and double with every attempt.
+type RetryConfig struct {
+	MaxAttempts int `yaml:"max_attempts"`
+	BaseDelay   int `yaml:"base_delay"`
+	MaxDelay    int `yaml:"max_delay"`
+}
+
+// ApprovalConfig holds outgoing notes and messages as drafts until a person
+// approves them with the review command. When Companies is set, only profiles
+// whose company contains one of the entries need approval.
+type ApprovalConfig struct {
+	Enabled   bool     `yaml:"enabled"`
+	Companies []string `yaml:"companies"`
 }
 
 type StealthConfig struct {
@@ -131,6 +238,43 @@ type LoggingConfig struct {
 	Level  string `yaml:"level"`
 	Format string `yaml:"format"`
 	Output string `yaml:"output"`
+	// Components overrides Level per component, e.g. search: debug
+	Components map[string]string `yaml:"components"`
+	// Campaign tags every log line, so runs for different campaigns can be
+	// told apart; the -campaign flag overrides it
+	Campaign string `yaml:"campaign"`
+	// Sinks replaces Format and Output with several outputs written at once
+	Sinks []LogSinkConfig `yaml:"sinks"`
+}
+
+// LogSinkConfig is one log output. File outputs rotate at MaxSizeMB or
+// after MaxAgeHours, keeping MaxBackups archives. MaxSizeMB and MaxBackups
+// default to 100 and 7 when 0 and are disabled by -1; MaxAgeHours 0 disables
+// rotation by age. The defaults also apply to a file in logging.output.
+type LogSinkConfig struct {
+	Output      string `yaml:"output"`
+	Format      string `yaml:"format"`
+	Level       string `yaml:"level"`
+	MaxSizeMB   int    `yaml:"max_size_mb"`
+	MaxAgeHours int    `yaml:"max_age_hours"`
+	MaxBackups  int    `yaml:"max_backups"`
+	Compress    bool   `yaml:"compress"`
+}
+
+// MetricsConfig controls the Prometheus /metrics endpoint. Listen defaults
+// to localhost so the endpoint is not exposed by accident.
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Listen  string `yaml:"listen"`
+}
+
+// ArtifactsConfig controls the screenshot, page HTML and URL saved when a
+// lookup or action fails. Each run writes to its own directory under Dir,
+// and MaxPerRun caps how many failures are captured (0 for no limit).
+type ArtifactsConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	Dir       string `yaml:"dir"`
+	MaxPerRun int    `yaml:"max_per_run"`
 }
 
 // LoadConfig loads configuration from YAML file and environment variables
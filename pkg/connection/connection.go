@@ -5,20 +5,34 @@ import (
 	"strings"
 	"time"
 
+	"linkedin-automation/pkg/approval"
+	"linkedin-automation/pkg/artifacts"
 	"linkedin-automation/pkg/config"
 	"linkedin-automation/pkg/database"
 	"linkedin-automation/pkg/logger"
+	"linkedin-automation/pkg/metrics"
+	"linkedin-automation/pkg/profileurl"
+	"linkedin-automation/pkg/retry"
+	"linkedin-automation/pkg/selectors"
 	stealthpkg "linkedin-automation/pkg/stealth"
+	"linkedin-automation/pkg/templates"
 
 	"github.com/go-rod/rod"
 )
 
+var log = logger.Component("connection")
+
+// verifyTimeout is how long to wait for the profile to show a sent invitation
+const verifyTimeout = 10 * time.Second
+
 // Connection handles connection requests
 type Connection struct {
-	config  *config.Config
-	page    *rod.Page
-	stealth *stealthpkg.Stealth
-	db      *database.DB
+	config   *config.Config
+	page     *rod.Page
+	stealth  *stealthpkg.Stealth
+	db       *database.DB
+	retries  *retry.Tracker
+	approval *approval.Gate
 }
 
 // ConnectionRequest represents a connection request
@@ -30,146 +44,356 @@ type ConnectionRequest struct {
 	SentAt    time.Time `json:"sent_at"`
 }
 
+// Outcome categorises what happened when a connection was attempted on a profile
+type Outcome string
+
+const (
+	OutcomeConnected        Outcome = "connected"         // invitation sent
+	OutcomeFollowOnly       Outcome = "follow-only"       // profile only offers Follow
+	OutcomeEmailRequired    Outcome = "email-required"    // invitation needs the member's email address
+	OutcomeAlreadyPending   Outcome = "already-pending"   // an invitation is already outstanding
+	OutcomeAlreadyConnected Outcome = "already-connected" // profile is a 1st-degree connection
+	OutcomeFailed           Outcome = "failed"            // the attempt errored and may be retried
+)
+
+// Status maps an outcome onto the connection_requests status column
+func (o Outcome) Status() string {
+	switch o {
+	case OutcomeConnected, OutcomeAlreadyPending:
+		return "pending"
+	case OutcomeAlreadyConnected:
+		return "accepted"
+	case OutcomeFailed:
+		return "failed"
+	default:
+		return "skipped"
+	}
+}
+
 // NewConnection creates a new connection instance
 func NewConnection(cfg *config.Config, page *rod.Page, stealth *stealthpkg.Stealth, db *database.DB) *Connection {
 	return &Connection{
-		config:  cfg,
-		page:    page,
-		stealth: stealth,
-		db:      db,
+		config:   cfg,
+		page:     page,
+		stealth:  stealth,
+		db:       db,
+		retries:  retry.NewTracker(cfg, db, retry.ActionConnect),
+		approval: approval.NewGate(cfg, db, retry.ActionConnect),
 	}
 }
 
 // SendConnectionRequests sends connection requests to profiles
 func (c *Connection) SendConnectionRequests() error {
-	logger.Warn("Connection requests functionality not fully implemented", nil)
-	logger.Info("Connection operations placeholder", nil)
-
 	// Get profiles that haven't been contacted yet
 	profiles, err := c.getUncontactedProfiles()
 	if err != nil {
-		logger.Warn("Failed to get uncontacted profiles", map[string]interface{}{
-			"error": err.Error(),
-		})
+		log.Warn("Failed to get uncontacted profiles",
+			"error", err,
+		)
 		return err
 	}
 
 	if len(profiles) == 0 {
-		logger.Info("No uncontacted profiles found", nil)
+		log.Info("No uncontacted profiles found")
 		return nil
 	}
 
 	// Check daily limit
 	sentToday, err := c.getConnectionsSentToday()
 	if err != nil {
-		logger.Warn("Failed to check daily limit", map[string]interface{}{
-			"error": err.Error(),
-		})
+		log.Warn("Failed to check daily limit",
+			"error", err,
+		)
 		return err
 	}
 
 	remaining := c.config.Connections.DailyLimit - sentToday
 	if remaining <= 0 {
-		logger.Warn("Daily connection limit reached", nil)
+		log.Warn("Daily connection limit reached")
 		return fmt.Errorf("daily limit reached")
 	}
 
-	// Send connection requests
-	sent := 0
+	// Send connection requests. Drafts written for approval count against
+	// the limit too, so the review queue grows no faster than we can send.
+	sent, drafted := 0, 0
 	for _, profile := range profiles {
-		if sent >= remaining {
+		if sent+drafted >= remaining {
 			break
 		}
 
-		if err := c.sendConnectionRequest(profile); err != nil {
-			logger.Warn("Failed to send connection request", map[string]interface{}{
-				"profile_url": profile.URL,
-				"error":       err.Error(),
-			})
+		note := c.generatePersonalizedNote(profile)
+
+		var draft *database.PendingAction
+		if c.approval.Required(profile.URL) {
+			var created bool
+			draft, created, err = c.approval.Draft(profile.URL, 0, func() (string, int) { return note, -1 })
+			if err != nil {
+				log.Warn("Failed to queue note for approval",
+					"profile_url", profile.URL,
+					"error", err,
+				)
+				continue
+			}
+			if created {
+				drafted++
+			}
+			if draft.Status != database.ApprovalApproved {
+				continue
+			}
+			note = draft.Body
+		}
+
+		actionStart := time.Now()
+		outcome, note, err := c.sendConnectionRequest(profile, note)
+		metrics.ObserveAction("connect", actionStart)
+		if err != nil {
+			log.Warn("Failed to send connection request",
+				"profile_url", profile.URL,
+				"error", err,
+				artifacts.Capture(c.page, "connect", err),
+			)
+		}
+
+		if err := c.saveConnectionRequest(profile, note, outcome); err != nil {
+			log.Warn("Failed to save connection outcome",
+				"profile_url", profile.URL,
+				"outcome", string(outcome),
+				"error", err,
+			)
+		}
+
+		if outcome == OutcomeFailed {
+			metrics.InvitationsFailed.Inc()
+			c.retries.Failure(profile.URL, note, err)
+			continue
+		}
+		c.retries.Success(profile.URL)
+
+		// Only a draft whose approved text actually went out counts as sent
+		if draft != nil && outcome == OutcomeConnected && note == draft.Body {
+			c.approval.Sent(draft)
+		}
+
+		if outcome != OutcomeConnected {
+			log.Info("Connection request not sent",
+				"profile_url", profile.URL,
+				"outcome", string(outcome),
+			)
 			continue
 		}
 
 		sent++
-		logger.Info("Connection request sent", map[string]interface{}{
-			"profile_url": profile.URL,
-		})
+		metrics.InvitationsSent.Inc()
+		log.Info("Connection request sent",
+			"profile_url", profile.URL,
+		)
 
 		// Apply cooldown
 		c.stealth.RandomDelay()
 		time.Sleep(time.Duration(c.config.Stealth.RateLimiting.ConnectionCooldown) * time.Millisecond)
 	}
 
-	logger.Info("Connection requests completed", map[string]interface{}{
-		"sent": sent,
-	})
+	log.Info("Connection requests completed",
+		"sent", sent,
+		"drafted", drafted,
+	)
 
 	return nil
 }
 
-func (c *Connection) sendConnectionRequest(profile database.Profile) error {
+// sendConnectionRequest works out which connect flow the profile offers and
+// follows it, adding note when the flow asks for one. It returns the outcome
+// and the note that was sent, if any.
+func (c *Connection) sendConnectionRequest(profile database.Profile, note string) (Outcome, string, error) {
 	// Navigate to profile
+	loadStart := time.Now()
 	if err := c.page.Navigate(profile.URL); err != nil {
-		return fmt.Errorf("failed to navigate to profile: %w", err)
+		return OutcomeFailed, "", fmt.Errorf("failed to navigate to profile: %w", err)
 	}
 
 	c.page.MustWaitLoad()
+	metrics.ObservePageLoad("profile", loadStart)
 
 	// Scroll to load the connect button
 	c.stealth.ScrollHumanLike(500)
 	c.stealth.RandomDelay()
 
-	// Find connect button
-	connectBtn := c.page.MustElement("button[aria-label*='Connect']")
+	// Profiles we already have a relationship with need no invitation
+	if selectors.Has(c.page, "pending_button") {
+		return OutcomeAlreadyPending, "", nil
+	}
+	if c.isFirstDegree() {
+		return OutcomeAlreadyConnected, "", nil
+	}
+
+	connectBtn, err := c.findConnectButton()
+	if err != nil {
+		return OutcomeFailed, "", err
+	}
 	if connectBtn == nil {
-		return fmt.Errorf("connect button not found")
+		if selectors.Has(c.page, "follow_button") {
+			return OutcomeFollowOnly, "", nil
+		}
+		return OutcomeFailed, "", retry.Permanent(fmt.Errorf("connect button not found"))
 	}
 
 	// Click connect button
 	c.stealth.HumanClick(connectBtn)
 
 	// Wait for modal
-	c.page.MustElement("div[data-test-modal]").MustWaitVisible()
-
-	// Check if "Send without note" is available
-	sendWithoutNoteBtn := c.page.MustElements("button[aria-label='Send without a note']")
-	if len(sendWithoutNoteBtn) > 0 {
-		c.stealth.HumanClick(sendWithoutNoteBtn[0])
-	} else {
-		// Add a note
-		addNoteBtn := c.page.MustElement("button[aria-label='Add a note']")
-		if addNoteBtn != nil {
-			c.stealth.HumanClick(addNoteBtn)
-
-			// Wait for note textarea
-			noteTextarea := c.page.MustElement("textarea[name='message']")
-			noteTextarea.MustWaitVisible()
-
-			// Generate personalized note
-			note := c.generatePersonalizedNote(profile)
-
-			// Type the note
-			c.stealth.HumanType(noteTextarea, note)
-
-			// Click send
-			sendBtn := c.page.MustElement("button[aria-label='Send invitation']")
-			c.stealth.HumanClick(sendBtn)
+	modal, err := selectors.Wait(c.page, "invitation_modal", verifyTimeout)
+	if err != nil {
+		return OutcomeFailed, "", fmt.Errorf("invitation modal did not open: %w", err)
+	}
+	modal.MustWaitVisible()
+
+	// Some members only accept invitations from people who know their email
+	if selectors.Has(c.page, "invitation_email_input") {
+		c.dismissModal()
+		return OutcomeEmailRequired, "", nil
+	}
+
+	// Without a note, "Send without a note" is all it takes
+	if note == "" {
+		if sendWithoutNoteBtn, err := selectors.Find(c.page, "send_without_note_button"); err == nil {
+			c.stealth.HumanClick(sendWithoutNoteBtn)
+			if err := c.confirmPending(); err != nil {
+				return OutcomeFailed, "", err
+			}
+			return OutcomeConnected, "", nil
+		}
+	}
+
+	// Add the note; never fall back to sending without it, which would drop
+	// a note that may have been approved
+	addNoteBtn, err := selectors.Find(c.page, "add_note_button")
+	if err != nil {
+		c.dismissModal()
+		if note != "" {
+			return OutcomeFailed, "", fmt.Errorf("invitation modal has no option to add a note")
 		}
+		return OutcomeFailed, "", fmt.Errorf("invitation modal has no send option")
 	}
 
-	// Save to database
-	return c.saveConnectionRequest(profile.ID, "sent")
+	c.stealth.HumanClick(addNoteBtn)
+
+	// Wait for note textarea
+	noteTextarea, err := selectors.Wait(c.page, "note_textarea", verifyTimeout)
+	if err != nil {
+		c.dismissModal()
+		return OutcomeFailed, "", fmt.Errorf("failed to find note textarea: %w", err)
+	}
+	noteTextarea.MustWaitVisible()
+
+	// Type the note
+	c.stealth.HumanType(noteTextarea, note)
+
+	// Click send
+	sendBtn, err := selectors.Find(c.page, "send_invitation_button")
+	if err != nil {
+		c.dismissModal()
+		return OutcomeFailed, note, fmt.Errorf("failed to find send button: %w", err)
+	}
+	c.stealth.HumanClick(sendBtn)
+
+	if err := c.confirmPending(); err != nil {
+		return OutcomeFailed, note, err
+	}
+
+	return OutcomeConnected, note, nil
 }
 
-func (c *Connection) generatePersonalizedNote(profile database.Profile) string {
-	note := c.config.Connections.DefaultNote
+// confirmPending waits for the profile to show the invitation as pending, or
+// for LinkedIn's "invitation sent" toast, so that a click which did nothing
+// is recorded as a failure rather than as a sent request
+func (c *Connection) confirmPending() error {
+	deadline := time.Now().Add(verifyTimeout)
 
-	// Replace placeholders
-	note = strings.ReplaceAll(note, "{name}", c.extractFirstName(profile.Name))
+	for {
+		if selectors.Has(c.page, "pending_button") ||
+			c.hasText("toast", "invitation") {
+			return nil
+		}
 
-	return note
+		if time.Now().After(deadline) {
+			c.dismissModal()
+			return fmt.Errorf("invitation not confirmed: profile does not show Pending")
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
 }
 
-func (c *Connection) extractFirstName(fullName string) string {
+// findConnectButton returns the Connect action from the profile header or,
+// when LinkedIn tucks it away, from the "More" actions menu. A nil element
+// with a nil error means the profile does not offer Connect at all.
+func (c *Connection) findConnectButton() (*rod.Element, error) {
+	if el, err := selectors.Find(c.page, "connect_button"); err == nil {
+		return el, nil
+	}
+
+	moreBtn, err := selectors.Find(c.page, "more_actions_button")
+	if err != nil {
+		return nil, nil
+	}
+
+	c.stealth.HumanClick(moreBtn)
+	c.stealth.RandomDelay()
+
+	el, err := selectors.Find(c.page, "more_actions_connect")
+	if err != nil {
+		// Close the menu again so it does not cover the Follow button
+		c.stealth.HumanClick(moreBtn)
+		return nil, nil
+	}
+
+	return el, nil
+}
+
+// isFirstDegree reports whether the profile header shows a 1st-degree badge
+func (c *Connection) isFirstDegree() bool {
+	return strings.Contains(selectors.Text(c.page, "connection_degree_badge"), "1st")
+}
+
+// dismissModal closes an open invitation modal without sending
+func (c *Connection) dismissModal() {
+	if el, err := selectors.Find(c.page, "modal_dismiss_button"); err == nil {
+		c.stealth.HumanClick(el)
+	}
+}
+
+// hasText reports whether the element registered as name contains text,
+// ignoring case
+func (c *Connection) hasText(name, text string) bool {
+	content := selectors.Text(c.page, name)
+	return strings.Contains(strings.ToLower(content), strings.ToLower(text))
+}
+
+// contactable reports whether a profile is neither suppressed nor blocked
+// by the retry tracker. A failed check counts as not contactable.
+func (c *Connection) contactable(profileURL string) bool {
+	suppressed, err := c.db.IsSuppressed(profileURL)
+	if err != nil {
+		log.Warn("Failed to check suppression",
+			"profile_url", profileURL,
+			"error", err,
+		)
+		return false
+	}
+	return !suppressed && c.retries.Ready(profileURL)
+}
+
+func (c *Connection) generatePersonalizedNote(profile database.Profile) string {
+	return templates.Fill(c.config.Connections.DefaultNote, NoteVars(&profile))
+}
+
+// NoteVars returns the placeholder values available to connection notes
+func NoteVars(profile *database.Profile) map[string]string {
+	return map[string]string{
+		"name": extractFirstName(profile.Name),
+	}
+}
+
+func extractFirstName(fullName string) string {
 	parts := strings.Split(fullName, " ")
 	if len(parts) > 0 {
 		return parts[0]
@@ -179,13 +403,26 @@ func (c *Connection) extractFirstName(fullName string) string {
 
 func (c *Connection) getUncontactedProfiles() ([]database.Profile, error) {
 	rows, err := c.db.Query(`
-		SELECT id, url, name, headline, location, found_at
+		SELECT id, url, name, headline, COALESCE(company, ''), location, found_at
 		FROM profiles
 		WHERE id NOT IN (
-			SELECT profile_id FROM connection_requests WHERE status IN ('sent', 'accepted')
+			SELECT profile_id FROM connection_requests
+			WHERE profile_id IS NOT NULL AND status != 'failed'
+		)
+		AND COALESCE(connection_degree, 0) != 1
+		AND url NOT IN (
+			SELECT profile_url FROM action_retries
+			WHERE action = ? AND (state = ? OR next_attempt_at > datetime('now'))
+		)
+		AND url NOT IN (SELECT profile_url FROM suppressions)
+		AND url NOT IN (SELECT profile_url FROM inbound_invitations)
+		AND url NOT IN (
+			SELECT profile_url FROM pending_actions
+			WHERE action = ? AND status IN (?, ?)
 		)
 		LIMIT 100
-	`)
+	`, retry.ActionConnect, database.RetryStateDead,
+		retry.ActionConnect, database.ApprovalPending, database.ApprovalRejected)
 	if err != nil {
 		return nil, err
 	}
@@ -194,10 +431,18 @@ func (c *Connection) getUncontactedProfiles() ([]database.Profile, error) {
 	var profiles []database.Profile
 	for rows.Next() {
 		var p database.Profile
-		err := rows.Scan(&p.ID, &p.URL, &p.Name, &p.Headline, &p.Location, &p.FoundAt)
+		err := rows.Scan(&p.ID, &p.URL, &p.Name, &p.Headline, &p.Company, &p.Location, &p.FoundAt)
 		if err != nil {
 			continue
 		}
+		// Rows stored before URLs were canonicalised may not match what
+		// the other tables are keyed by, so the filters above missed them;
+		// check again under the canonical URL. "db dedupe" rewrites them.
+		canonical := profileurl.Canonical(p.URL)
+		if canonical != p.URL && !c.contactable(canonical) {
+			continue
+		}
+		p.URL = canonical
 		profiles = append(profiles, p)
 	}
 
@@ -205,20 +450,15 @@ func (c *Connection) getUncontactedProfiles() ([]database.Profile, error) {
 }
 
 func (c *Connection) getConnectionsSentToday() (int, error) {
-	var count int
-	err := c.db.QueryRow(`
-		SELECT COUNT(*) FROM connection_requests
-		WHERE DATE(sent_at) = DATE('now')
-	`).Scan(&count)
-
-	return count, err
+	return c.db.CountConnectionsSentToday()
 }
 
-func (c *Connection) saveConnectionRequest(profileID int, status string) error {
-	_, err := c.db.Exec(`
-		INSERT INTO connection_requests (profile_id, status, sent_at)
-		VALUES (?, ?, ?)
-	`, profileID, status, time.Now())
-
-	return err
+func (c *Connection) saveConnectionRequest(profile database.Profile, note string, outcome Outcome) error {
+	return c.db.AddConnectionRequest(&database.ConnectionRequest{
+		ProfileID:  profile.ID,
+		ProfileURL: profile.URL,
+		Note:       note,
+		Status:     outcome.Status(),
+		Outcome:    string(outcome),
+	})
 }
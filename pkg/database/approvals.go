@@ -0,0 +1,124 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Approval statuses of a pending action
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+	ApprovalSent     = "sent"
+)
+
+// PendingAction is a drafted connection note or message waiting for, or
+// having passed, human review
+type PendingAction struct {
+	ID            int64
+	Action        string // "connect", "message"
+	ProfileID     int64
+	ProfileURL    string
+	SequenceStep  int
+	TemplateIndex int
+	Body          string
+	Status        string
+	CreatedAt     time.Time
+	ReviewedAt    *time.Time
+	SentAt        *time.Time
+}
+
+const pendingActionColumns = `id, action, COALESCE(profile_id, 0), profile_url, sequence_step,
+	template_index, COALESCE(body, ''), status, created_at, reviewed_at, sent_at`
+
+// AddPendingAction stores a draft for review. A draft that already exists for
+// the same action, profile and step is left as it is.
+func (db *DB) AddPendingAction(a *PendingAction) error {
+	var profileID interface{}
+	if a.ProfileID != 0 {
+		profileID = a.ProfileID
+	}
+
+	_, err := db.conn.Exec(`INSERT OR IGNORE INTO pending_actions
+		(action, profile_id, profile_url, sequence_step, template_index, body, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		a.Action, profileID, a.ProfileURL, a.SequenceStep, a.TemplateIndex, a.Body, ApprovalPending)
+	return err
+}
+
+// GetPendingAction returns the draft for an action, profile and step, or nil
+// if none was written
+func (db *DB) GetPendingAction(action, profileURL string, step int) (*PendingAction, error) {
+	row := db.conn.QueryRow(`SELECT `+pendingActionColumns+` FROM pending_actions
+		WHERE action = ? AND profile_url = ? AND sequence_step = ?`, action, profileURL, step)
+	a, err := scanPendingAction(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return a, err
+}
+
+// GetPendingActionByID returns a draft by id, or nil if it does not exist
+func (db *DB) GetPendingActionByID(id int64) (*PendingAction, error) {
+	row := db.conn.QueryRow(`SELECT `+pendingActionColumns+` FROM pending_actions WHERE id = ?`, id)
+	a, err := scanPendingAction(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return a, err
+}
+
+// GetPendingActions lists drafts with the given status, oldest first. An
+// empty status lists all of them.
+func (db *DB) GetPendingActions(status string) ([]*PendingAction, error) {
+	rows, err := db.conn.Query(`SELECT `+pendingActionColumns+` FROM pending_actions
+		WHERE (? = '' OR status = ?)
+		ORDER BY created_at, id`, status, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var actions []*PendingAction
+	for rows.Next() {
+		a, err := scanPendingAction(rows)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, a)
+	}
+
+	return actions, rows.Err()
+}
+
+// ReviewPendingAction records a reviewer's decision on a draft that has not
+// been sent yet. A non-empty body replaces the drafted text. It reports
+// whether a draft was updated.
+func (db *DB) ReviewPendingAction(id int64, status, body string) (bool, error) {
+	res, err := db.conn.Exec(`UPDATE pending_actions
+		SET status = ?, body = CASE WHEN ? != '' THEN ? ELSE body END, reviewed_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status != ?`, status, body, body, id, ApprovalSent)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// MarkPendingActionSent records that an approved draft went out
+func (db *DB) MarkPendingActionSent(id int64) error {
+	_, err := db.conn.Exec(`UPDATE pending_actions SET status = ?, sent_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, ApprovalSent, id)
+	return err
+}
+
+func scanPendingAction(row scanner) (*PendingAction, error) {
+	var a PendingAction
+	err := row.Scan(&a.ID, &a.Action, &a.ProfileID, &a.ProfileURL, &a.SequenceStep,
+		&a.TemplateIndex, &a.Body, &a.Status, &a.CreatedAt, &a.ReviewedAt, &a.SentAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
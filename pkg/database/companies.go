@@ -0,0 +1,103 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Company is a LinkedIn company page whose employees were searched for
+type Company struct {
+	ID            int64
+	LinkedInID    string // numeric company id used by the currentCompany facet
+	UniversalName string // the slug in linkedin.com/company/<slug>
+	Name          string
+	URL           string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+const companyColumns = `id, linkedin_id, COALESCE(universal_name, ''), COALESCE(name, ''),
+	COALESCE(url, ''), created_at, updated_at`
+
+// UpsertCompany stores a company keyed by its LinkedIn id, refreshing the
+// name, slug and URL when they are known, and sets company.ID
+func (db *DB) UpsertCompany(company *Company) error {
+	_, err := db.conn.Exec(`INSERT INTO companies (linkedin_id, universal_name, name, url) VALUES (?, ?, ?, ?)
+		ON CONFLICT(linkedin_id) DO UPDATE SET
+			universal_name = COALESCE(NULLIF(excluded.universal_name, ''), universal_name),
+			name = COALESCE(NULLIF(excluded.name, ''), name),
+			url = COALESCE(NULLIF(excluded.url, ''), url),
+			updated_at = CURRENT_TIMESTAMP`,
+		company.LinkedInID, company.UniversalName, company.Name, company.URL)
+	if err != nil {
+		return err
+	}
+
+	return db.conn.QueryRow(`SELECT id FROM companies WHERE linkedin_id = ?`, company.LinkedInID).Scan(&company.ID)
+}
+
+// GetCompanyByLinkedInID returns the company with a LinkedIn id, or nil
+func (db *DB) GetCompanyByLinkedInID(linkedInID string) (*Company, error) {
+	row := db.conn.QueryRow(`SELECT `+companyColumns+` FROM companies WHERE linkedin_id = ?`, linkedInID)
+
+	company, err := scanCompany(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return company, err
+}
+
+// FindCompany returns a stored company whose slug or name matches, ignoring
+// case, or nil
+func (db *DB) FindCompany(nameOrSlug string) (*Company, error) {
+	key := strings.ToLower(strings.TrimSpace(nameOrSlug))
+	row := db.conn.QueryRow(`SELECT `+companyColumns+` FROM companies
+		WHERE LOWER(universal_name) = ? OR LOWER(name) = ?
+		ORDER BY updated_at DESC LIMIT 1`, key, key)
+
+	company, err := scanCompany(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return company, err
+}
+
+// LinkProfileCompany records that a profile works at company. The profile's
+// company name is filled in when it has none.
+func (db *DB) LinkProfileCompany(profileURL string, company *Company) error {
+	_, err := db.conn.Exec(`UPDATE profiles SET company_id = ?,
+			company = CASE WHEN COALESCE(company, '') = '' THEN ? ELSE company END,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE url = ?`, company.ID, company.Name, profileURL)
+	return err
+}
+
+// GetCompanyProfiles returns the profiles linked to a company, most recently
+// found first
+func (db *DB) GetCompanyProfiles(companyID int64) ([]*Profile, error) {
+	rows, err := db.conn.Query(`SELECT `+profileColumns+` FROM profiles
+		WHERE company_id = ? ORDER BY found_at DESC, id DESC`, companyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []*Profile
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, rows.Err()
+}
+
+func scanCompany(row scanner) (*Company, error) {
+	var c Company
+	if err := row.Scan(&c.ID, &c.LinkedInID, &c.UniversalName, &c.Name, &c.URL, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
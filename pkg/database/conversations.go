@@ -0,0 +1,160 @@
+package database
+
+import (
+	"time"
+)
+
+// Message directions
+const (
+	DirectionInbound  = "inbound"
+	DirectionOutbound = "outbound"
+)
+
+// Conversation is a messaging thread with a single participant
+type Conversation struct {
+	ID              int64
+	ThreadID        string
+	ProfileID       int64
+	ProfileURL      string
+	ParticipantName string
+	Label           string // classification of the latest labelled reply, if any
+	LastMessageAt   *time.Time
+	LastSyncedAt    time.Time
+}
+
+// ConversationMessage is one message in a conversation, sent or received
+type ConversationMessage struct {
+	ID             int64
+	ConversationID int64
+	MessageURN     string
+	Direction      string // "inbound", "outbound"
+	Sender         string
+	Body           string
+	SentAt         time.Time
+}
+
+// ReplyStats summarises how many messaged profiles replied
+type ReplyStats struct {
+	Conversations int // conversations with at least one outbound message
+	Replied       int // of those, conversations with at least one inbound message
+}
+
+// Rate returns the share of conversations that received a reply
+func (s ReplyStats) Rate() float64 {
+	if s.Conversations == 0 {
+		return 0
+	}
+	return float64(s.Replied) / float64(s.Conversations)
+}
+
+// UpsertConversation creates or refreshes a conversation by thread id and
+// returns its row id
+func (db *DB) UpsertConversation(c *Conversation) (int64, error) {
+	query := `INSERT INTO conversations (thread_id, profile_id, profile_url, participant_name, last_synced_at)
+	          VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+	          ON CONFLICT(thread_id) DO UPDATE SET
+	              profile_id = COALESCE(excluded.profile_id, conversations.profile_id),
+	              profile_url = COALESCE(excluded.profile_url, conversations.profile_url),
+	              participant_name = excluded.participant_name,
+	              last_synced_at = CURRENT_TIMESTAMP`
+
+	var profileID, profileURL interface{}
+	if c.ProfileID != 0 {
+		profileID = c.ProfileID
+	}
+	if c.ProfileURL != "" {
+		profileURL = c.ProfileURL
+	}
+
+	if _, err := db.conn.Exec(query, c.ThreadID, profileID, profileURL, c.ParticipantName); err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err := db.conn.QueryRow(`SELECT id FROM conversations WHERE thread_id = ?`, c.ThreadID).Scan(&id)
+	return id, err
+}
+
+// AddConversationMessage stores a message unless it was already synced and
+// reports whether it was new
+func (db *DB) AddConversationMessage(msg *ConversationMessage) (bool, error) {
+	res, err := db.conn.Exec(`INSERT OR IGNORE INTO conversation_messages
+		(conversation_id, message_urn, direction, sender, body, sent_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		msg.ConversationID, msg.MessageURN, msg.Direction, msg.Sender, msg.Body, timestamp(msg.SentAt))
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	_, err = db.conn.Exec(`UPDATE conversations
+		SET last_message_at = MAX(COALESCE(last_message_at, ''), ?)
+		WHERE id = ?`, timestamp(msg.SentAt), msg.ConversationID)
+	return true, err
+}
+
+// SetConversationLabel stores the classification of a conversation's latest reply
+func (db *DB) SetConversationLabel(id int64, label string) error {
+	_, err := db.conn.Exec(`UPDATE conversations SET label = ?, labeled_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, label, id)
+	return err
+}
+
+// GetLabelCounts counts conversations per reply label
+func (db *DB) GetLabelCounts() (map[string]int, error) {
+	rows, err := db.conn.Query(`SELECT label, COUNT(*) FROM conversations
+		WHERE COALESCE(label, '') != '' GROUP BY label`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var label string
+		var n int
+		if err := rows.Scan(&label, &n); err != nil {
+			return nil, err
+		}
+		counts[label] = n
+	}
+
+	return counts, rows.Err()
+}
+
+// HasReplied reports whether any conversation with the profile contains an
+// inbound message
+func (db *DB) HasReplied(profileURL string) (bool, error) {
+	var count int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM conversation_messages m
+		JOIN conversations c ON c.id = m.conversation_id
+		WHERE c.profile_url = ? AND m.direction = ?`, profileURL, DirectionInbound).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetReplyStats counts conversations we wrote in and how many got a reply
+func (db *DB) GetReplyStats() (*ReplyStats, error) {
+	var stats ReplyStats
+	err := db.conn.QueryRow(`SELECT
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN EXISTS (
+				SELECT 1 FROM conversation_messages i
+				WHERE i.conversation_id = c.id AND i.direction = ?
+			) THEN 1 ELSE 0 END), 0)
+		FROM conversations c
+		WHERE EXISTS (
+			SELECT 1 FROM conversation_messages o
+			WHERE o.conversation_id = c.id AND o.direction = ?
+		)`, DirectionInbound, DirectionOutbound).Scan(&stats.Conversations, &stats.Replied)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
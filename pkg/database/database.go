@@ -2,9 +2,12 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"time"
 
+	"linkedin-automation/pkg/profileurl"
+
 	_ "modernc.org/sqlite"
 )
 
@@ -15,41 +18,60 @@ type DB struct {
 
 // Profile represents a LinkedIn profile
 type Profile struct {
-	ID          int64
-	URL         string
-	Name        string
-	Headline    string
-	Title       string
-	Company     string
-	Location    string
-	FoundAt     time.Time
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	ID       int64
+	URL      string
+	Name     string
+	Headline string
+	Title    string
+	Company  string
+	Location string
+	Source   string // "search", "inbound", "post-engagement"
+	// Network details captured from the search result card
+	ConnectionDegree      int // 1, 2 or 3; 0 when unknown or out of network
+	MutualConnections     int
+	MutualConnectionNames []string
+	CardAction            string // "connect", "message", "follow"
+	FoundAt               time.Time
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
 }
 
 // ConnectionRequest represents a sent connection request
 type ConnectionRequest struct {
-	ID          int64
-	ProfileID   int64
-	ProfileURL  string
-	Note        string
-	Status      string // "pending", "accepted", "rejected"
-	SentAt      time.Time
-	AcceptedAt  *time.Time
+	ID         int64
+	ProfileID  int64
+	ProfileURL string
+	Note       string
+	Status     string // "pending", "accepted", "rejected", "skipped", "failed"
+	Outcome    string // "connected", "follow-only", "email-required", "already-pending", "already-connected", "failed"
+	SentAt     time.Time
+	AcceptedAt *time.Time
 }
 
 // Message represents a sent message
 type Message struct {
-	ID          int64
-	ProfileID   int64
-	ProfileURL  string
-	Content     string
-	SentAt      time.Time
+	ID           int64
+	ProfileID    int64
+	ProfileURL   string
+	Content      string
+	SequenceStep int // 1-based follow-up sequence step, 0 for one-off messages
+	// TemplateIndex is the position of the chosen template in the configured
+	// list, or -1 when the text did not come from a template
+	TemplateIndex int
+	Status        string // "sent", or "failed" when the message did not show up in the thread
+	Error         string
+	SentAt        time.Time
 }
 
+// Message statuses
+const (
+	MessageSent   = "sent"
+	MessageFailed = "failed"
+)
+
 // DailyStats tracks daily activity limits
 type DailyStats struct {
-	Date           time.Time
+	Date            time.Time
 	ConnectionsSent int
 	MessagesSent    int
 }
@@ -80,6 +102,12 @@ func (db *DB) init() error {
 			title TEXT,
 			company TEXT,
 			location TEXT,
+			source TEXT DEFAULT 'search',
+			connection_degree INTEGER DEFAULT 0,
+			mutual_connections INTEGER DEFAULT 0,
+			mutual_connection_names TEXT,
+			card_action TEXT,
+			company_id INTEGER,
 			found_at DATETIME,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
@@ -90,6 +118,7 @@ func (db *DB) init() error {
 			profile_url TEXT NOT NULL,
 			note TEXT,
 			status TEXT DEFAULT 'pending',
+			outcome TEXT,
 			sent_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			accepted_at DATETIME,
 			FOREIGN KEY (profile_id) REFERENCES profiles(id)
@@ -99,6 +128,8 @@ func (db *DB) init() error {
 			profile_id INTEGER,
 			profile_url TEXT NOT NULL,
 			content TEXT NOT NULL,
+			sequence_step INTEGER DEFAULT 0,
+			template_index INTEGER DEFAULT -1,
 			sent_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (profile_id) REFERENCES profiles(id)
 		)`,
@@ -107,11 +138,161 @@ func (db *DB) init() error {
 			connections_sent INTEGER DEFAULT 0,
 			messages_sent INTEGER DEFAULT 0
 		)`,
+		`CREATE TABLE IF NOT EXISTS inbound_invitations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			profile_id INTEGER,
+			profile_url TEXT UNIQUE NOT NULL,
+			name TEXT,
+			headline TEXT,
+			company TEXT,
+			decision TEXT NOT NULL,
+			rule TEXT,
+			processed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (profile_id) REFERENCES profiles(id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS action_retries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			action TEXT NOT NULL,
+			profile_url TEXT NOT NULL,
+			payload TEXT,
+			attempts INTEGER DEFAULT 0,
+			last_error TEXT,
+			next_attempt_at DATETIME,
+			state TEXT DEFAULT 'retrying',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (action, profile_url)
+		)`,
+		`CREATE TABLE IF NOT EXISTS follow_up_sequences (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			profile_id INTEGER,
+			profile_url TEXT UNIQUE NOT NULL,
+			next_step INTEGER DEFAULT 0,
+			status TEXT DEFAULT 'active',
+			next_due_at DATETIME,
+			last_sent_at DATETIME,
+			started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			stopped_at DATETIME,
+			FOREIGN KEY (profile_id) REFERENCES profiles(id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS suppressions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			profile_url TEXT UNIQUE NOT NULL,
+			reason TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS conversations (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			thread_id TEXT UNIQUE NOT NULL,
+			profile_id INTEGER,
+			profile_url TEXT,
+			participant_name TEXT,
+			last_message_at DATETIME,
+			last_synced_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (profile_id) REFERENCES profiles(id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS conversation_messages (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			conversation_id INTEGER NOT NULL,
+			message_urn TEXT UNIQUE NOT NULL,
+			direction TEXT NOT NULL,
+			sender TEXT,
+			body TEXT,
+			sent_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (conversation_id) REFERENCES conversations(id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS pending_actions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			action TEXT NOT NULL,
+			profile_id INTEGER,
+			profile_url TEXT NOT NULL,
+			sequence_step INTEGER DEFAULT 0,
+			template_index INTEGER DEFAULT -1,
+			body TEXT,
+			status TEXT DEFAULT 'pending',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			reviewed_at DATETIME,
+			sent_at DATETIME,
+			UNIQUE (action, profile_url, sequence_step),
+			FOREIGN KEY (profile_id) REFERENCES profiles(id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS location_cache (
+			query TEXT PRIMARY KEY,
+			urn TEXT NOT NULL,
+			name TEXT,
+			resolved_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS saved_searches (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT UNIQUE NOT NULL,
+			params TEXT NOT NULL,
+			interval_minutes INTEGER DEFAULT 1440,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			last_run_at DATETIME
+		)`,
+		`CREATE TABLE IF NOT EXISTS search_runs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			saved_search_id INTEGER,
+			params TEXT NOT NULL,
+			status TEXT DEFAULT 'running',
+			pages INTEGER DEFAULT 0,
+			profiles_seen INTEGER DEFAULT 0,
+			profiles_new INTEGER DEFAULT 0,
+			search_url TEXT,
+			cards_seen INTEGER DEFAULT 0,
+			duplicates INTEGER DEFAULT 0,
+			extraction_failures INTEGER DEFAULT 0,
+			duration_ms INTEGER DEFAULT 0,
+			error TEXT,
+			started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			finished_at DATETIME,
+			FOREIGN KEY (saved_search_id) REFERENCES saved_searches(id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS saved_search_results (
+			saved_search_id INTEGER NOT NULL,
+			profile_url TEXT NOT NULL,
+			first_run_id INTEGER NOT NULL,
+			last_run_id INTEGER NOT NULL,
+			PRIMARY KEY (saved_search_id, profile_url),
+			FOREIGN KEY (saved_search_id) REFERENCES saved_searches(id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS companies (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			linkedin_id TEXT UNIQUE NOT NULL,
+			universal_name TEXT,
+			name TEXT,
+			url TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS post_engagements (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			post_url TEXT NOT NULL,
+			profile_id INTEGER,
+			profile_url TEXT NOT NULL,
+			kind TEXT NOT NULL,
+			reaction TEXT,
+			comment TEXT NOT NULL DEFAULT '',
+			found_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (post_url, profile_url, kind, comment),
+			FOREIGN KEY (profile_id) REFERENCES profiles(id)
+		)`,
 		`CREATE INDEX IF NOT EXISTS idx_profiles_url ON profiles(url)`,
 		`CREATE INDEX IF NOT EXISTS idx_connection_requests_profile_url ON connection_requests(profile_url)`,
 		`CREATE INDEX IF NOT EXISTS idx_connection_requests_status ON connection_requests(status)`,
 		`CREATE INDEX IF NOT EXISTS idx_messages_profile_url ON messages(profile_url)`,
 		`CREATE INDEX IF NOT EXISTS idx_daily_stats_date ON daily_stats(date)`,
+		`CREATE INDEX IF NOT EXISTS idx_inbound_invitations_decision ON inbound_invitations(decision)`,
+		`CREATE INDEX IF NOT EXISTS idx_action_retries_state ON action_retries(action, state, next_attempt_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_follow_up_sequences_due ON follow_up_sequences(status, next_due_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_conversations_profile_url ON conversations(profile_url)`,
+		`CREATE INDEX IF NOT EXISTS idx_conversation_messages_conversation ON conversation_messages(conversation_id, direction)`,
+		`CREATE INDEX IF NOT EXISTS idx_pending_actions_status ON pending_actions(status)`,
+		`CREATE INDEX IF NOT EXISTS idx_search_runs_saved_search ON search_runs(saved_search_id, started_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_saved_search_results_first_run ON saved_search_results(first_run_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_post_engagements_profile_url ON post_engagements(profile_url)`,
 	}
 
 	for _, query := range queries {
@@ -120,9 +301,98 @@ func (db *DB) init() error {
 		}
 	}
 
+	// Columns added after the initial schema; CREATE TABLE IF NOT EXISTS
+	// leaves older databases untouched, so add them explicitly
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"connection_requests", "outcome", "TEXT"},
+		{"profiles", "source", "TEXT DEFAULT 'search'"},
+		{"profiles", "connection_degree", "INTEGER DEFAULT 0"},
+		{"profiles", "mutual_connections", "INTEGER DEFAULT 0"},
+		{"profiles", "mutual_connection_names", "TEXT"},
+		{"profiles", "card_action", "TEXT"},
+		{"messages", "sequence_step", "INTEGER DEFAULT 0"},
+		{"messages", "template_index", "INTEGER DEFAULT -1"},
+		{"messages", "status", "TEXT DEFAULT 'sent'"},
+		{"messages", "error", "TEXT"},
+		{"conversations", "label", "TEXT"},
+		{"conversations", "labeled_at", "DATETIME"},
+		{"search_runs", "last_page", "INTEGER DEFAULT 0"},
+		{"profiles", "company_id", "INTEGER"},
+		{"search_runs", "search_url", "TEXT"},
+		{"search_runs", "cards_seen", "INTEGER DEFAULT 0"},
+		{"search_runs", "duplicates", "INTEGER DEFAULT 0"},
+		{"search_runs", "extraction_failures", "INTEGER DEFAULT 0"},
+		{"search_runs", "duration_ms", "INTEGER DEFAULT 0"},
+	}
+
+	for _, c := range columns {
+		if err := db.addColumn(c.table, c.column, c.definition); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", c.table, c.column, err)
+		}
+	}
+
+	// Indexes on added columns can only be created once the columns exist
+	indexes := []string{
+		`CREATE INDEX IF NOT EXISTS idx_connection_requests_outcome ON connection_requests(outcome)`,
+		`CREATE INDEX IF NOT EXISTS idx_profiles_source ON profiles(source)`,
+		`CREATE INDEX IF NOT EXISTS idx_profiles_connection_degree ON profiles(connection_degree)`,
+		`CREATE INDEX IF NOT EXISTS idx_profiles_company_id ON profiles(company_id)`,
+	}
+
+	for _, query := range indexes {
+		if _, err := db.conn.Exec(query); err != nil {
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// addColumn adds a column to an existing table unless it is already present
+func (db *DB) addColumn(table, column, definition string) error {
+	rows, err := db.conn.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+
+	exists := false
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+		if name == column {
+			exists = true
+		}
+	}
+	rows.Close()
+
+	if exists {
+		return nil
+	}
+
+	_, err = db.conn.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// timestamp formats t the way SQLite's CURRENT_TIMESTAMP does, so stored
+// values can be compared with datetime('now') and read back as time.Time
+func timestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
 // Close closes the database connection
 func (db *DB) Close() error {
 	return db.conn.Close()
@@ -143,38 +413,141 @@ func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
 	return db.conn.Query(query, args...)
 }
 
-// AddProfile adds a new profile to the database
+// AddProfile adds a new profile to the database under its canonical URL. For
+// a profile that is already known, the search card details (degree, mutual
+// connections and card action) are refreshed where the new values are set;
+// everything else is kept as first recorded.
 func (db *DB) AddProfile(profile *Profile) error {
-	query := `INSERT OR IGNORE INTO profiles (url, name, headline, title, company, location, found_at) 
-	          VALUES (?, ?, ?, ?, ?, ?, ?)`
-	_, err := db.conn.Exec(query, profile.URL, profile.Name, profile.Headline, profile.Title, profile.Company, profile.Location, profile.FoundAt)
+	profile.URL = profileurl.Canonical(profile.URL)
+
+	source := profile.Source
+	if source == "" {
+		source = "search"
+	}
+
+	names, err := encodeNames(profile.MutualConnectionNames)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO profiles (url, name, headline, title, company, location, source,
+	                                connection_degree, mutual_connections, mutual_connection_names, card_action, found_at) 
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	          ON CONFLICT(url) DO UPDATE SET
+	              connection_degree = CASE WHEN excluded.connection_degree > 0
+	                  THEN excluded.connection_degree ELSE connection_degree END,
+	              mutual_connections = CASE WHEN excluded.mutual_connections > 0
+	                  THEN excluded.mutual_connections ELSE mutual_connections END,
+	              mutual_connection_names = COALESCE(excluded.mutual_connection_names, mutual_connection_names),
+	              card_action = COALESCE(NULLIF(excluded.card_action, ''), card_action),
+	              updated_at = CURRENT_TIMESTAMP`
+	_, err = db.conn.Exec(query, profile.URL, profile.Name, profile.Headline, profile.Title, profile.Company, profile.Location, source,
+		profile.ConnectionDegree, profile.MutualConnections, names, profile.CardAction, profile.FoundAt)
+	return err
+}
+
+// SetConnectionDegree records the network distance of a profile, e.g. 1
+// once an invitation from them has been accepted
+func (db *DB) SetConnectionDegree(profileURL string, degree int) error {
+	_, err := db.conn.Exec(`UPDATE profiles SET connection_degree = ?, updated_at = CURRENT_TIMESTAMP WHERE url = ?`,
+		degree, profileurl.Canonical(profileURL))
 	return err
 }
 
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+const profileColumns = `id, url, COALESCE(name, ''), COALESCE(headline, ''), COALESCE(title, ''),
+	COALESCE(company, ''), COALESCE(location, ''), COALESCE(source, 'search'),
+	COALESCE(connection_degree, 0), COALESCE(mutual_connections, 0),
+	COALESCE(mutual_connection_names, ''), COALESCE(card_action, ''),
+	found_at, created_at, updated_at`
+
 // GetProfileByURL retrieves a profile by URL
 func (db *DB) GetProfileByURL(url string) (*Profile, error) {
-	query := `SELECT id, url, name, headline, title, company, location, found_at, created_at, updated_at 
-	          FROM profiles WHERE url = ?`
-	row := db.conn.QueryRow(query, url)
+	row := db.conn.QueryRow(`SELECT `+profileColumns+` FROM profiles WHERE url = ?`, url)
 
-	var profile Profile
-	err := row.Scan(&profile.ID, &profile.URL, &profile.Name, &profile.Headline, &profile.Title,
-		&profile.Company, &profile.Location, &profile.FoundAt, &profile.CreatedAt, &profile.UpdatedAt)
+	profile, err := scanProfile(row)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
+	return profile, err
+}
+
+// GetProfiles returns the most recently added profiles, up to limit, or all
+// profiles when limit is 0
+func (db *DB) GetProfiles(limit int) ([]*Profile, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := db.conn.Query(`SELECT `+profileColumns+` FROM profiles ORDER BY id DESC LIMIT ?`, limit)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	var profiles []*Profile
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+
+	return profiles, rows.Err()
+}
+
+func scanProfile(row scanner) (*Profile, error) {
+	var profile Profile
+	var names string
+	err := row.Scan(&profile.ID, &profile.URL, &profile.Name, &profile.Headline, &profile.Title,
+		&profile.Company, &profile.Location, &profile.Source,
+		&profile.ConnectionDegree, &profile.MutualConnections, &names, &profile.CardAction,
+		&profile.FoundAt, &profile.CreatedAt, &profile.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if profile.MutualConnectionNames, err = decodeNames(names); err != nil {
+		return nil, err
+	}
 
 	return &profile, nil
 }
 
+// encodeNames stores a list of names as a JSON array, or NULL when empty
+func encodeNames(names []string) (interface{}, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(names)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// decodeNames reverses encodeNames
+func decodeNames(data string) ([]string, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var names []string
+	if err := json.Unmarshal([]byte(data), &names); err != nil {
+		return nil, fmt.Errorf("invalid name list: %w", err)
+	}
+	return names, nil
+}
+
 // AddConnectionRequest adds a new connection request
 func (db *DB) AddConnectionRequest(req *ConnectionRequest) error {
-	query := `INSERT INTO connection_requests (profile_id, profile_url, note, status) 
-	          VALUES (?, ?, ?, ?)`
-	_, err := db.conn.Exec(query, req.ProfileID, req.ProfileURL, req.Note, req.Status)
+	query := `INSERT INTO connection_requests (profile_id, profile_url, note, status, outcome) 
+	          VALUES (?, ?, ?, ?, ?)`
+	_, err := db.conn.Exec(query, req.ProfileID, req.ProfileURL, req.Note, req.Status, req.Outcome)
 	return err
 }
 
@@ -203,7 +576,7 @@ func (db *DB) UpdateConnectionRequestStatus(profileURL string, status string) er
 
 // GetPendingConnections returns all pending connection requests
 func (db *DB) GetPendingConnections() ([]*ConnectionRequest, error) {
-	query := `SELECT id, profile_id, profile_url, note, status, sent_at, accepted_at 
+	query := `SELECT id, profile_id, profile_url, COALESCE(note, ''), status, COALESCE(outcome, ''), sent_at, accepted_at 
 	          FROM connection_requests WHERE status = 'pending'`
 	rows, err := db.conn.Query(query)
 	if err != nil {
@@ -214,8 +587,8 @@ func (db *DB) GetPendingConnections() ([]*ConnectionRequest, error) {
 	var requests []*ConnectionRequest
 	for rows.Next() {
 		var req ConnectionRequest
-		err := rows.Scan(&req.ID, &req.ProfileID, &req.ProfileURL, &req.Note, 
-			&req.Status, &req.SentAt, &req.AcceptedAt)
+		err := rows.Scan(&req.ID, &req.ProfileID, &req.ProfileURL, &req.Note,
+			&req.Status, &req.Outcome, &req.SentAt, &req.AcceptedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -227,16 +600,24 @@ func (db *DB) GetPendingConnections() ([]*ConnectionRequest, error) {
 
 // AddMessage adds a new message
 func (db *DB) AddMessage(msg *Message) error {
-	query := `INSERT INTO messages (profile_id, profile_url, content) VALUES (?, ?, ?)`
-	_, err := db.conn.Exec(query, msg.ProfileID, msg.ProfileURL, msg.Content)
+	status := msg.Status
+	if status == "" {
+		status = MessageSent
+	}
+
+	query := `INSERT INTO messages (profile_id, profile_url, content, sequence_step, template_index, status, error)
+	          VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := db.conn.Exec(query, msg.ProfileID, msg.ProfileURL, msg.Content, msg.SequenceStep, msg.TemplateIndex,
+		status, msg.Error)
 	return err
 }
 
-// HasMessage checks if a message was already sent to a profile
+// HasMessage checks if a message was already sent to a profile. Failed sends
+// do not count.
 func (db *DB) HasMessage(profileURL string) (bool, error) {
-	query := `SELECT COUNT(*) FROM messages WHERE profile_url = ?`
+	query := `SELECT COUNT(*) FROM messages WHERE profile_url = ? AND COALESCE(status, ?) = ?`
 	var count int
-	err := db.conn.QueryRow(query, profileURL).Scan(&count)
+	err := db.conn.QueryRow(query, profileURL, MessageSent, MessageSent).Scan(&count)
 	if err != nil {
 		return false, err
 	}
@@ -283,3 +664,37 @@ func (db *DB) IncrementDailyMessages(date time.Time) error {
 	return err
 }
 
+// CountConnectionsSentToday counts the invitations actually sent today,
+// which is what the daily connection limit applies to
+func (db *DB) CountConnectionsSentToday() (int, error) {
+	var count int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM connection_requests
+		WHERE DATE(sent_at) = DATE('now') AND outcome = 'connected'`).Scan(&count)
+	return count, err
+}
+
+// GetQueueDepths counts the items waiting in each work queue: actions
+// backing off before a retry, dead-lettered actions, drafts awaiting
+// approval and follow-up sequences whose next step is due
+func (db *DB) GetQueueDepths() (map[string]int, error) {
+	queries := map[string]struct {
+		query string
+		args  []interface{}
+	}{
+		"retry":       {`SELECT COUNT(*) FROM action_retries WHERE state = ?`, []interface{}{RetryStateRetrying}},
+		"dead_letter": {`SELECT COUNT(*) FROM action_retries WHERE state = ?`, []interface{}{RetryStateDead}},
+		"approval":    {`SELECT COUNT(*) FROM pending_actions WHERE status = ?`, []interface{}{ApprovalPending}},
+		"follow_up": {`SELECT COUNT(*) FROM follow_up_sequences
+			WHERE status = ? AND next_due_at <= datetime('now')`, []interface{}{SequenceActive}},
+	}
+
+	depths := make(map[string]int, len(queries))
+	for queue, q := range queries {
+		var count int
+		if err := db.conn.QueryRow(q.query, q.args...).Scan(&count); err != nil {
+			return nil, fmt.Errorf("failed to count %s queue: %w", queue, err)
+		}
+		depths[queue] = count
+	}
+	return depths, nil
+}
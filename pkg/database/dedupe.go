@@ -0,0 +1,327 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"linkedin-automation/pkg/profileurl"
+)
+
+// profileURLTables are the tables that key rows by profile URL
+var profileURLTables = []string{
+	"connection_requests",
+	"messages",
+	"inbound_invitations",
+	"action_retries",
+	"follow_up_sequences",
+	"suppressions",
+	"conversations",
+	"pending_actions",
+	"saved_search_results",
+	"post_engagements",
+}
+
+// profileIDTables are the tables that also reference profiles by id
+var profileIDTables = []string{
+	"connection_requests",
+	"messages",
+	"inbound_invitations",
+	"follow_up_sequences",
+	"conversations",
+	"pending_actions",
+	"post_engagements",
+}
+
+// mergedProfileColumns are copied from a duplicate onto the kept profile when
+// the kept profile has no value for them
+var mergedProfileColumns = []string{"name", "headline", "title", "company", "location", "card_action", "company_id"}
+
+// collisionMerges fold a row at a legacy URL into the row holding the same
+// unique key at the canonical URL, keeping whichever state went further, so
+// that dropping the legacy row loses nothing that matters. Each statement
+// takes the legacy URL and then the canonical URL. Tables without a unique
+// key on profile_url never collide and have no entry.
+var collisionMerges = map[string]string{
+	// A stopped sequence stays stopped, and steps already sent are not
+	// sent again
+	"follow_up_sequences": `UPDATE follow_up_sequences AS c SET
+		status = CASE WHEN c.status = '` + SequenceActive + `' THEN o.status ELSE c.status END,
+		stopped_at = COALESCE(c.stopped_at, o.stopped_at),
+		next_step = MAX(c.next_step, o.next_step),
+		next_due_at = CASE WHEN o.next_step > c.next_step THEN o.next_due_at ELSE c.next_due_at END,
+		last_sent_at = COALESCE(MAX(c.last_sent_at, o.last_sent_at), c.last_sent_at, o.last_sent_at),
+		started_at = MIN(c.started_at, o.started_at)
+		FROM (SELECT * FROM follow_up_sequences WHERE profile_url = ?) AS o
+		WHERE c.profile_url = ?`,
+
+	// A sent or reviewed draft wins over one still waiting for review
+	"pending_actions": `UPDATE pending_actions AS c SET
+		status = o.status,
+		body = o.body,
+		template_index = o.template_index,
+		reviewed_at = o.reviewed_at,
+		sent_at = o.sent_at
+		FROM (SELECT * FROM pending_actions WHERE profile_url = ?) AS o
+		WHERE c.profile_url = ? AND c.action = o.action AND c.sequence_step = o.sequence_step
+		AND ` + approvalRank("o.status") + ` > ` + approvalRank("c.status"),
+
+	"suppressions": `UPDATE suppressions AS c SET
+		reason = CASE
+			WHEN COALESCE(c.reason, '') = '' THEN o.reason
+			WHEN COALESCE(o.reason, '') = '' OR o.reason = c.reason THEN c.reason
+			ELSE c.reason || '; ' || o.reason
+		END,
+		created_at = MIN(c.created_at, o.created_at)
+		FROM (SELECT * FROM suppressions WHERE profile_url = ?) AS o
+		WHERE c.profile_url = ?`,
+
+	// A dead letter stays dead and attempts are not forgotten
+	"action_retries": `UPDATE action_retries AS c SET
+		state = CASE WHEN o.state = '` + RetryStateDead + `' THEN o.state ELSE c.state END,
+		attempts = MAX(c.attempts, o.attempts),
+		last_error = CASE
+			WHEN o.state = '` + RetryStateDead + `' AND c.state != '` + RetryStateDead + `' THEN o.last_error
+			WHEN o.updated_at > c.updated_at THEN o.last_error
+			ELSE c.last_error
+		END,
+		next_attempt_at = COALESCE(MAX(c.next_attempt_at, o.next_attempt_at), c.next_attempt_at, o.next_attempt_at),
+		updated_at = MAX(c.updated_at, o.updated_at)
+		FROM (SELECT * FROM action_retries WHERE profile_url = ?) AS o
+		WHERE c.profile_url = ? AND c.action = o.action`,
+
+	// The latest triage decision wins
+	"inbound_invitations": `UPDATE inbound_invitations AS c SET
+		decision = o.decision,
+		rule = o.rule,
+		processed_at = o.processed_at
+		FROM (SELECT * FROM inbound_invitations WHERE profile_url = ?) AS o
+		WHERE c.profile_url = ? AND o.processed_at > c.processed_at`,
+
+	"saved_search_results": `UPDATE saved_search_results AS c SET
+		first_run_id = MIN(c.first_run_id, o.first_run_id),
+		last_run_id = MAX(c.last_run_id, o.last_run_id)
+		FROM (SELECT * FROM saved_search_results WHERE profile_url = ?) AS o
+		WHERE c.profile_url = ? AND c.saved_search_id = o.saved_search_id`,
+
+	"post_engagements": `UPDATE post_engagements AS c SET
+		reaction = COALESCE(NULLIF(c.reaction, ''), o.reaction),
+		found_at = MIN(c.found_at, o.found_at)
+		FROM (SELECT * FROM post_engagements WHERE profile_url = ?) AS o
+		WHERE c.profile_url = ? AND c.post_url = o.post_url AND c.kind = o.kind AND c.comment = o.comment`,
+}
+
+// approvalRank orders approval statuses by how far the draft got
+func approvalRank(column string) string {
+	return fmt.Sprintf(`CASE %s WHEN '%s' THEN 3 WHEN '%s' THEN 2 WHEN '%s' THEN 1 ELSE 0 END`,
+		column, ApprovalSent, ApprovalApproved, ApprovalRejected)
+}
+
+// DedupeSummary counts what DedupeProfiles changed
+type DedupeSummary struct {
+	// Groups is the number of canonical URLs that had more than one profile
+	Groups int
+	// Merged is the number of duplicate profiles folded into another
+	Merged int
+	// Rewritten is the number of kept profiles whose URL was rewritten
+	Rewritten int
+	// RowsUpdated counts related rows moved onto the canonical URL or profile
+	RowsUpdated int64
+	// RowsMerged counts related rows folded into the row the canonical URL
+	// already had, e.g. a second suppression or follow-up sequence
+	RowsMerged int64
+}
+
+// DedupeProfiles merges profiles whose URLs canonicalise to the same value.
+// The oldest profile of each group is kept, takes over blank fields from the
+// others and moves to the canonical URL; requests, messages and every other
+// row that pointed at a duplicate are moved onto it. With dryRun set the
+// changes are rolled back and only the summary is returned.
+func (db *DB) DedupeProfiles(dryRun bool) (*DedupeSummary, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	summary := &DedupeSummary{}
+
+	groups, order, err := profileGroups(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, canonical := range order {
+		group := groups[canonical]
+		keep := group[0]
+
+		if len(group) > 1 {
+			summary.Groups++
+			for _, dup := range group[1:] {
+				n, err := mergeProfile(tx, keep.id, dup.id)
+				if err != nil {
+					return nil, fmt.Errorf("failed to merge profile %d into %d: %w", dup.id, keep.id, err)
+				}
+				summary.Merged++
+				summary.RowsUpdated += n
+			}
+		}
+
+		if keep.url != canonical {
+			if _, err := tx.Exec(`UPDATE profiles SET url = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, canonical, keep.id); err != nil {
+				return nil, fmt.Errorf("failed to rewrite profile %d: %w", keep.id, err)
+			}
+			summary.Rewritten++
+		}
+	}
+
+	for _, table := range profileURLTables {
+		updated, merged, err := canonicaliseTable(tx, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rewrite %s: %w", table, err)
+		}
+		summary.RowsUpdated += updated
+		summary.RowsMerged += merged
+	}
+
+	if dryRun {
+		return summary, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit: %w", err)
+	}
+
+	return summary, nil
+}
+
+type profileKey struct {
+	id  int64
+	url string
+}
+
+// profileGroups returns the profiles that need work, grouped by canonical URL
+// with the oldest first, and the canonical URLs in a stable order
+func profileGroups(tx *sql.Tx) (map[string][]profileKey, []string, error) {
+	rows, err := tx.Query(`SELECT id, url FROM profiles ORDER BY id`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read profiles: %w", err)
+	}
+	defer rows.Close()
+
+	groups := make(map[string][]profileKey)
+	var order []string
+	for rows.Next() {
+		var p profileKey
+		if err := rows.Scan(&p.id, &p.url); err != nil {
+			return nil, nil, err
+		}
+		canonical := profileurl.Canonical(p.url)
+		if _, ok := groups[canonical]; !ok {
+			order = append(order, canonical)
+		}
+		groups[canonical] = append(groups[canonical], p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// Keep only groups with duplicates or a URL to rewrite
+	var pending []string
+	for _, canonical := range order {
+		group := groups[canonical]
+		if len(group) == 1 && group[0].url == canonical {
+			delete(groups, canonical)
+			continue
+		}
+		pending = append(pending, canonical)
+	}
+
+	return groups, pending, nil
+}
+
+// mergeProfile folds profile dup into keep and deletes it, returning how
+// many related rows were repointed
+func mergeProfile(tx *sql.Tx, keep, dup int64) (int64, error) {
+	sets := make([]string, len(mergedProfileColumns))
+	for i, column := range mergedProfileColumns {
+		sets[i] = fmt.Sprintf("%[1]s = COALESCE(NULLIF(%[1]s, ''), (SELECT %[1]s FROM profiles WHERE id = ?))", column)
+	}
+	args := make([]interface{}, 0, len(sets)+1)
+	for range sets {
+		args = append(args, dup)
+	}
+	args = append(args, keep)
+
+	query := `UPDATE profiles SET ` + strings.Join(sets, ", ") + `, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	if _, err := tx.Exec(query, args...); err != nil {
+		return 0, err
+	}
+
+	var moved int64
+	for _, table := range profileIDTables {
+		res, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET profile_id = ? WHERE profile_id = ?`, table), keep, dup)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := res.RowsAffected()
+		moved += n
+	}
+
+	if _, err := tx.Exec(`DELETE FROM profiles WHERE id = ?`, dup); err != nil {
+		return 0, err
+	}
+
+	return moved, nil
+}
+
+// canonicaliseTable moves rows of table onto canonical profile URLs. Rows
+// that would collide with a unique row already at the canonical URL are
+// merged into it with collisionMerges and then dropped.
+func canonicaliseTable(tx *sql.Tx, table string) (updated, merged int64, err error) {
+	rows, err := tx.Query(fmt.Sprintf(`SELECT DISTINCT profile_url FROM %s WHERE profile_url IS NOT NULL`, table))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var urls []string
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		if canonical := profileurl.Canonical(u); canonical != u {
+			urls = append(urls, u)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	for _, u := range urls {
+		canonical := profileurl.Canonical(u)
+		if merge, ok := collisionMerges[table]; ok {
+			if _, err := tx.Exec(merge, u, canonical); err != nil {
+				return 0, 0, fmt.Errorf("failed to merge conflicting rows: %w", err)
+			}
+		}
+
+		res, err := tx.Exec(fmt.Sprintf(`UPDATE OR IGNORE %s SET profile_url = ? WHERE profile_url = ?`, table), canonical, u)
+		if err != nil {
+			return 0, 0, err
+		}
+		n, _ := res.RowsAffected()
+		updated += n
+
+		res, err = tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE profile_url = ?`, table), u)
+		if err != nil {
+			return 0, 0, err
+		}
+		n, _ = res.RowsAffected()
+		merged += n
+	}
+
+	return updated, merged, nil
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"time"
+)
+
+// Kinds of post engagement
+const (
+	EngagementReaction = "reaction"
+	EngagementComment  = "comment"
+)
+
+// ReactionUnknown is stored when a reaction's type could not be read
+const ReactionUnknown = "unknown"
+
+// PostEngagement records that a profile reacted to or commented on a post
+type PostEngagement struct {
+	ID         int64
+	PostURL    string
+	ProfileID  int64
+	ProfileURL string
+	Kind       string // "reaction" or "comment"
+	Reaction   string // e.g. "like", "celebrate" or "unknown"; empty for comments
+	Comment    string // the comment text; empty for reactions and image-only comments
+	FoundAt    time.Time
+}
+
+// AddPostEngagement records an engagement, reporting whether it was new. A
+// reaction is stored once per post and profile, with its latest type; each
+// distinct comment is stored separately.
+func (db *DB) AddPostEngagement(e *PostEngagement) (bool, error) {
+	res, err := db.conn.Exec(`INSERT INTO post_engagements (post_url, profile_id, profile_url, kind, reaction, comment)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(post_url, profile_url, kind, comment) DO NOTHING`,
+		e.PostURL, e.ProfileID, e.ProfileURL, e.Kind, e.Reaction, e.Comment)
+	if err != nil {
+		return false, err
+	}
+
+	n, _ := res.RowsAffected()
+	if n == 0 && e.Reaction != "" && e.Reaction != ReactionUnknown {
+		// Reactions can change, e.g. from like to celebrate; an unreadable
+		// icon keeps the type recorded before
+		_, err = db.conn.Exec(`UPDATE post_engagements SET reaction = ?, profile_id = ?
+			WHERE post_url = ? AND profile_url = ? AND kind = ? AND comment = ?`,
+			e.Reaction, e.ProfileID, e.PostURL, e.ProfileURL, e.Kind, e.Comment)
+	}
+	return n > 0, err
+}
+
+// GetPostEngagements returns recorded engagements, newest first, optionally
+// limited to one post and one kind
+func (db *DB) GetPostEngagements(postURL, kind string) ([]*PostEngagement, error) {
+	rows, err := db.conn.Query(`SELECT id, post_url, COALESCE(profile_id, 0), profile_url, kind,
+			COALESCE(reaction, ''), comment, found_at
+		FROM post_engagements
+		WHERE (? = '' OR post_url = ?) AND (? = '' OR kind = ?)
+		ORDER BY found_at DESC, id DESC`, postURL, postURL, kind, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var engagements []*PostEngagement
+	for rows.Next() {
+		var e PostEngagement
+		if err := rows.Scan(&e.ID, &e.PostURL, &e.ProfileID, &e.ProfileURL, &e.Kind,
+			&e.Reaction, &e.Comment, &e.FoundAt); err != nil {
+			return nil, err
+		}
+		engagements = append(engagements, &e)
+	}
+
+	return engagements, rows.Err()
+}
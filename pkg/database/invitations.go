@@ -0,0 +1,62 @@
+package database
+
+import (
+	"time"
+)
+
+// InboundInvitation records how a received connection invitation was triaged
+type InboundInvitation struct {
+	ID          int64
+	ProfileID   int64
+	ProfileURL  string
+	Name        string
+	Headline    string
+	Company     string
+	Decision    string // "accept", "ignore", "review"
+	Rule        string // the rule that produced the decision, empty for review
+	ProcessedAt time.Time
+}
+
+// UpsertInboundInvitation records the latest decision for an inviter
+func (db *DB) UpsertInboundInvitation(inv *InboundInvitation) error {
+	query := `INSERT INTO inbound_invitations (profile_id, profile_url, name, headline, company, decision, rule, processed_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	          ON CONFLICT(profile_url) DO UPDATE SET
+	              profile_id = excluded.profile_id,
+	              name = excluded.name,
+	              headline = excluded.headline,
+	              company = excluded.company,
+	              decision = excluded.decision,
+	              rule = excluded.rule,
+	              processed_at = excluded.processed_at`
+	_, err := db.conn.Exec(query, inv.ProfileID, inv.ProfileURL, inv.Name, inv.Headline, inv.Company,
+		inv.Decision, inv.Rule, time.Now())
+	return err
+}
+
+// GetInboundInvitations returns triaged invitations, optionally filtered by decision
+func (db *DB) GetInboundInvitations(decision string) ([]*InboundInvitation, error) {
+	query := `SELECT id, COALESCE(profile_id, 0), profile_url, COALESCE(name, ''), COALESCE(headline, ''),
+	                 COALESCE(company, ''), decision, COALESCE(rule, ''), processed_at
+	          FROM inbound_invitations
+	          WHERE ? = '' OR decision = ?
+	          ORDER BY processed_at DESC`
+	rows, err := db.conn.Query(query, decision, decision)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invitations []*InboundInvitation
+	for rows.Next() {
+		var inv InboundInvitation
+		err := rows.Scan(&inv.ID, &inv.ProfileID, &inv.ProfileURL, &inv.Name, &inv.Headline,
+			&inv.Company, &inv.Decision, &inv.Rule, &inv.ProcessedAt)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, &inv)
+	}
+
+	return invitations, rows.Err()
+}
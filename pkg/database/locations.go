@@ -0,0 +1,27 @@
+package database
+
+import (
+	"database/sql"
+)
+
+// GetCachedLocation returns the geo URN and display name previously resolved
+// for a normalised location query. found is false when it was never resolved.
+func (db *DB) GetCachedLocation(query string) (urn, name string, found bool, err error) {
+	err = db.conn.QueryRow(`SELECT urn, COALESCE(name, '') FROM location_cache WHERE query = ?`, query).
+		Scan(&urn, &name)
+	if err == sql.ErrNoRows {
+		return "", "", false, nil
+	}
+	if err != nil {
+		return "", "", false, err
+	}
+	return urn, name, true, nil
+}
+
+// CacheLocation stores the geo URN resolved for a normalised location query
+func (db *DB) CacheLocation(query, urn, name string) error {
+	_, err := db.conn.Exec(`INSERT INTO location_cache (query, urn, name) VALUES (?, ?, ?)
+		ON CONFLICT(query) DO UPDATE SET urn = excluded.urn, name = excluded.name,
+			resolved_at = CURRENT_TIMESTAMP`, query, urn, name)
+	return err
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Retry states
+const (
+	RetryStateRetrying = "retrying"
+	RetryStateDead     = "dead"
+)
+
+// ActionRetry tracks failed attempts of an outbound action on a profile
+type ActionRetry struct {
+	ID            int64
+	Action        string // "connect", "message"
+	ProfileURL    string
+	Payload       string // the note or message that failed to send, if any
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	State         string // "retrying", "dead"
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+const actionRetryColumns = `id, action, profile_url, COALESCE(payload, ''), attempts, COALESCE(last_error, ''),
+	next_attempt_at, state, created_at, updated_at`
+
+// GetActionRetry returns the retry record for an action on a profile, or nil
+func (db *DB) GetActionRetry(action, profileURL string) (*ActionRetry, error) {
+	row := db.conn.QueryRow(`SELECT `+actionRetryColumns+` FROM action_retries
+		WHERE action = ? AND profile_url = ?`, action, profileURL)
+
+	r, err := scanActionRetry(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return r, err
+}
+
+// SaveActionRetry inserts or updates the retry record for an action on a profile
+func (db *DB) SaveActionRetry(r *ActionRetry) error {
+	query := `INSERT INTO action_retries (action, profile_url, payload, attempts, last_error, next_attempt_at, state)
+	          VALUES (?, ?, ?, ?, ?, ?, ?)
+	          ON CONFLICT(action, profile_url) DO UPDATE SET
+	              payload = excluded.payload,
+	              attempts = excluded.attempts,
+	              last_error = excluded.last_error,
+	              next_attempt_at = excluded.next_attempt_at,
+	              state = excluded.state,
+	              updated_at = CURRENT_TIMESTAMP`
+	_, err := db.conn.Exec(query, r.Action, r.ProfileURL, r.Payload, r.Attempts, r.LastError,
+		timestamp(r.NextAttemptAt), r.State)
+	return err
+}
+
+// DeleteActionRetry clears the retry record once an action succeeds
+func (db *DB) DeleteActionRetry(action, profileURL string) error {
+	_, err := db.conn.Exec(`DELETE FROM action_retries WHERE action = ? AND profile_url = ?`, action, profileURL)
+	return err
+}
+
+// IsActionBlocked reports whether an action on a profile is dead-lettered or
+// still waiting out its backoff
+func (db *DB) IsActionBlocked(action, profileURL string) (bool, error) {
+	var count int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM action_retries
+		WHERE action = ? AND profile_url = ?
+		AND (state = ? OR next_attempt_at > datetime('now'))`,
+		action, profileURL, RetryStateDead).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetActionRetries lists retry records, optionally filtered by action and state
+func (db *DB) GetActionRetries(action, state string) ([]*ActionRetry, error) {
+	rows, err := db.conn.Query(`SELECT `+actionRetryColumns+` FROM action_retries
+		WHERE (? = '' OR action = ?) AND (? = '' OR state = ?)
+		ORDER BY updated_at DESC`, action, action, state, state)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var retries []*ActionRetry
+	for rows.Next() {
+		r, err := scanActionRetry(rows)
+		if err != nil {
+			return nil, err
+		}
+		retries = append(retries, r)
+	}
+
+	return retries, rows.Err()
+}
+
+// RequeueActionRetry moves a dead-lettered action back to the retry queue
+// with its attempt count reset, so it is picked up by the next run
+func (db *DB) RequeueActionRetry(id int64) (bool, error) {
+	res, err := db.conn.Exec(`UPDATE action_retries
+		SET state = ?, attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND state = ?`, RetryStateRetrying, id, RetryStateDead)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// RequeueDeadActions requeues every dead-lettered action, optionally limited
+// to one action type, and returns how many were requeued
+func (db *DB) RequeueDeadActions(action string) (int64, error) {
+	res, err := db.conn.Exec(`UPDATE action_retries
+		SET state = ?, attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE state = ? AND (? = '' OR action = ?)`, RetryStateRetrying, RetryStateDead, action, action)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanActionRetry(row scanner) (*ActionRetry, error) {
+	var r ActionRetry
+	err := row.Scan(&r.ID, &r.Action, &r.ProfileURL, &r.Payload, &r.Attempts, &r.LastError,
+		&r.NextAttemptAt, &r.State, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
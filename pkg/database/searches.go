@@ -0,0 +1,339 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Search run statuses
+const (
+	SearchRunRunning   = "running"
+	SearchRunCompleted = "completed"
+	SearchRunFailed    = "failed"
+	// SearchRunStopped is a run ended by search.max_results with pages
+	// left; "search -resume" continues it
+	SearchRunStopped = "stopped"
+)
+
+// SavedSearch is a named people search that can be re-run
+type SavedSearch struct {
+	ID              int64
+	Name            string
+	Params          string // JSON encoded search parameters
+	IntervalMinutes int    // how often the search is due when run with -due
+	CreatedAt       time.Time
+	LastRunAt       *time.Time
+}
+
+// SearchRun records one execution of a search
+type SearchRun struct {
+	ID                 int64
+	SavedSearchID      int64 // 0 for ad-hoc searches
+	Params             string
+	SearchURL          string // the results URL the parameters resolved to
+	Status             string
+	Pages              int // result pages processed, across resumes
+	LastPage           int // number of the last completed page, the checkpoint for resuming
+	CardsSeen          int // result cards on the pages visited
+	ProfilesSeen       int
+	ProfilesNew        int
+	Duplicates         int // profiles skipped because they were already known
+	ExtractionFailures int // cards that could not be read
+	Duration           time.Duration
+	Error              string
+	StartedAt          time.Time
+	FinishedAt         *time.Time
+}
+
+// SearchQueryStats sums the runs made with the same parameters
+type SearchQueryStats struct {
+	Params       string
+	Runs         int
+	Pages        int
+	ProfilesSeen int
+	ProfilesNew  int
+	Duplicates   int
+	LastRunAt    time.Time
+}
+
+// SaveSearch creates a saved search or replaces the parameters and interval
+// of an existing one with the same name
+func (db *DB) SaveSearch(name, params string, intervalMinutes int) error {
+	_, err := db.conn.Exec(`INSERT INTO saved_searches (name, params, interval_minutes) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET params = excluded.params, interval_minutes = excluded.interval_minutes`,
+		name, params, intervalMinutes)
+	return err
+}
+
+const savedSearchColumns = `id, name, params, interval_minutes, created_at, last_run_at`
+
+// GetSavedSearch returns a saved search by name, or nil if there is none
+func (db *DB) GetSavedSearch(name string) (*SavedSearch, error) {
+	row := db.conn.QueryRow(`SELECT `+savedSearchColumns+` FROM saved_searches WHERE name = ?`, name)
+	ss, err := scanSavedSearch(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return ss, err
+}
+
+// GetSavedSearchByID returns a saved search by id, or nil if there is none
+func (db *DB) GetSavedSearchByID(id int64) (*SavedSearch, error) {
+	row := db.conn.QueryRow(`SELECT `+savedSearchColumns+` FROM saved_searches WHERE id = ?`, id)
+	ss, err := scanSavedSearch(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return ss, err
+}
+
+// GetSavedSearches returns all saved searches ordered by name
+func (db *DB) GetSavedSearches() ([]*SavedSearch, error) {
+	return db.querySavedSearches(`SELECT ` + savedSearchColumns + ` FROM saved_searches ORDER BY name`)
+}
+
+// GetDueSavedSearches returns saved searches that never ran or whose interval
+// has elapsed since the last run
+func (db *DB) GetDueSavedSearches() ([]*SavedSearch, error) {
+	return db.querySavedSearches(`SELECT ` + savedSearchColumns + ` FROM saved_searches
+		WHERE last_run_at IS NULL
+		   OR last_run_at <= datetime('now', '-' || interval_minutes || ' minutes')
+		ORDER BY COALESCE(last_run_at, ''), name`)
+}
+
+// DeleteSavedSearch removes a saved search along with its result history
+func (db *DB) DeleteSavedSearch(name string) (bool, error) {
+	ss, err := db.GetSavedSearch(name)
+	if err != nil || ss == nil {
+		return false, err
+	}
+
+	if _, err := db.conn.Exec(`DELETE FROM saved_search_results WHERE saved_search_id = ?`, ss.ID); err != nil {
+		return false, err
+	}
+	_, err = db.conn.Exec(`DELETE FROM saved_searches WHERE id = ?`, ss.ID)
+	return err == nil, err
+}
+
+func (db *DB) querySavedSearches(query string, args ...interface{}) ([]*SavedSearch, error) {
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var searches []*SavedSearch
+	for rows.Next() {
+		ss, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		searches = append(searches, ss)
+	}
+
+	return searches, rows.Err()
+}
+
+func scanSavedSearch(row scanner) (*SavedSearch, error) {
+	var ss SavedSearch
+	err := row.Scan(&ss.ID, &ss.Name, &ss.Params, &ss.IntervalMinutes, &ss.CreatedAt, &ss.LastRunAt)
+	if err != nil {
+		return nil, err
+	}
+	return &ss, nil
+}
+
+// StartSearchRun records the start of a search and returns the run id. Pass
+// 0 as savedSearchID for ad-hoc searches.
+func (db *DB) StartSearchRun(savedSearchID int64, params string) (int64, error) {
+	var id interface{}
+	if savedSearchID != 0 {
+		id = savedSearchID
+	}
+
+	res, err := db.conn.Exec(`INSERT INTO search_runs (saved_search_id, params, status) VALUES (?, ?, ?)`,
+		id, params, SearchRunRunning)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// UpdateSearchRun checkpoints the progress of a run
+func (db *DB) UpdateSearchRun(run *SearchRun) error {
+	_, err := db.conn.Exec(`UPDATE search_runs SET search_url = ?, pages = ?, last_page = ?, cards_seen = ?,
+		    profiles_seen = ?, profiles_new = ?, duplicates = ?, extraction_failures = ?, duration_ms = ?
+		WHERE id = ?`, run.SearchURL, run.Pages, run.LastPage, run.CardsSeen, run.ProfilesSeen, run.ProfilesNew,
+		run.Duplicates, run.ExtractionFailures, run.Duration.Milliseconds(), run.ID)
+	return err
+}
+
+// ReopenSearchRun marks an unfinished run as running again before it is resumed
+func (db *DB) ReopenSearchRun(id int64) error {
+	_, err := db.conn.Exec(`UPDATE search_runs SET status = ?, error = NULL, finished_at = NULL WHERE id = ?`,
+		SearchRunRunning, id)
+	return err
+}
+
+const searchRunColumns = `id, COALESCE(saved_search_id, 0), params, COALESCE(search_url, ''), status, pages,
+	COALESCE(last_page, 0), COALESCE(cards_seen, 0), profiles_seen, profiles_new, COALESCE(duplicates, 0),
+	COALESCE(extraction_failures, 0), COALESCE(duration_ms, 0), COALESCE(error, ''), started_at, finished_at`
+
+// GetSearchRun returns a run by id, or nil if there is none
+func (db *DB) GetSearchRun(id int64) (*SearchRun, error) {
+	row := db.conn.QueryRow(`SELECT `+searchRunColumns+` FROM search_runs WHERE id = ?`, id)
+	run, err := scanSearchRun(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return run, err
+}
+
+// GetLatestUnfinishedSearchRun returns the most recent run that did not
+// complete, or nil if every run completed
+func (db *DB) GetLatestUnfinishedSearchRun() (*SearchRun, error) {
+	row := db.conn.QueryRow(`SELECT `+searchRunColumns+` FROM search_runs
+		WHERE status != ? ORDER BY id DESC LIMIT 1`, SearchRunCompleted)
+	run, err := scanSearchRun(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return run, err
+}
+
+// GetSearchRuns returns the most recent runs, newest first. A savedSearchID
+// other than 0 limits them to that saved search.
+func (db *DB) GetSearchRuns(savedSearchID int64, limit int) ([]*SearchRun, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+
+	query := `SELECT ` + searchRunColumns + ` FROM search_runs`
+	args := []interface{}{}
+	if savedSearchID != 0 {
+		query += ` WHERE saved_search_id = ?`
+		args = append(args, savedSearchID)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []*SearchRun
+	for rows.Next() {
+		run, err := scanSearchRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+// GetSearchQueryStats sums runs by their parameters, the queries that found
+// the most new profiles first
+func (db *DB) GetSearchQueryStats() ([]*SearchQueryStats, error) {
+	rows, err := db.conn.Query(`SELECT params, COUNT(*), SUM(pages), SUM(profiles_seen), SUM(profiles_new),
+			SUM(COALESCE(duplicates, 0)), MAX(started_at)
+		FROM search_runs
+		GROUP BY params
+		ORDER BY SUM(profiles_new) DESC, MAX(started_at) DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []*SearchQueryStats
+	for rows.Next() {
+		var st SearchQueryStats
+		var lastRun string
+		if err := rows.Scan(&st.Params, &st.Runs, &st.Pages, &st.ProfilesSeen, &st.ProfilesNew,
+			&st.Duplicates, &lastRun); err != nil {
+			return nil, err
+		}
+		st.LastRunAt, _ = time.Parse("2006-01-02 15:04:05", lastRun)
+		stats = append(stats, &st)
+	}
+
+	return stats, rows.Err()
+}
+
+func scanSearchRun(row scanner) (*SearchRun, error) {
+	var run SearchRun
+	var durationMs int64
+	err := row.Scan(&run.ID, &run.SavedSearchID, &run.Params, &run.SearchURL, &run.Status, &run.Pages,
+		&run.LastPage, &run.CardsSeen, &run.ProfilesSeen, &run.ProfilesNew, &run.Duplicates,
+		&run.ExtractionFailures, &durationMs, &run.Error, &run.StartedAt, &run.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	run.Duration = time.Duration(durationMs) * time.Millisecond
+	return &run, nil
+}
+
+// FinishSearchRun stores the final counters and status of a run and, for
+// saved searches, the time of the last run
+func (db *DB) FinishSearchRun(run *SearchRun) error {
+	_, err := db.conn.Exec(`UPDATE search_runs
+		SET status = ?, search_url = ?, pages = ?, last_page = ?, cards_seen = ?, profiles_seen = ?,
+		    profiles_new = ?, duplicates = ?, extraction_failures = ?, duration_ms = ?, error = ?,
+		    finished_at = CURRENT_TIMESTAMP
+		WHERE id = ?`, run.Status, run.SearchURL, run.Pages, run.LastPage, run.CardsSeen, run.ProfilesSeen,
+		run.ProfilesNew, run.Duplicates, run.ExtractionFailures, run.Duration.Milliseconds(), run.Error, run.ID)
+	if err != nil || run.SavedSearchID == 0 {
+		return err
+	}
+
+	_, err = db.conn.Exec(`UPDATE saved_searches SET last_run_at = CURRENT_TIMESTAMP WHERE id = ?`, run.SavedSearchID)
+	return err
+}
+
+// RecordSearchResult notes that a run of a saved search found a profile and
+// reports whether no earlier run of that search had found it
+func (db *DB) RecordSearchResult(savedSearchID, runID int64, profileURL string) (bool, error) {
+	res, err := db.conn.Exec(`INSERT OR IGNORE INTO saved_search_results
+		(saved_search_id, profile_url, first_run_id, last_run_id) VALUES (?, ?, ?, ?)`,
+		savedSearchID, profileURL, runID, runID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n > 0 {
+		return true, nil
+	}
+
+	_, err = db.conn.Exec(`UPDATE saved_search_results SET last_run_id = ?
+		WHERE saved_search_id = ? AND profile_url = ?`, runID, savedSearchID, profileURL)
+	return false, err
+}
+
+// GetNewSearchResults returns the profiles a run found for the first time
+func (db *DB) GetNewSearchResults(runID int64) ([]*Profile, error) {
+	rows, err := db.conn.Query(`SELECT `+profileColumns+` FROM profiles
+		WHERE url IN (SELECT profile_url FROM saved_search_results WHERE first_run_id = ?)
+		ORDER BY name`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []*Profile
+	for rows.Next() {
+		p, err := scanProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, p)
+	}
+
+	return profiles, rows.Err()
+}
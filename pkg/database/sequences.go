@@ -0,0 +1,88 @@
+package database
+
+import (
+	"time"
+)
+
+// Sequence statuses
+const (
+	SequenceActive     = "active"
+	SequenceCompleted  = "completed"
+	SequenceReplied    = "replied"
+	SequenceSuppressed = "suppressed"
+	SequenceRejected   = "rejected"
+)
+
+// FollowUpSequence tracks a profile's position in the follow-up sequence
+type FollowUpSequence struct {
+	ID         int64
+	ProfileID  int64
+	ProfileURL string
+	NextStep   int // 0-based index of the next step to send
+	Status     string
+	NextDueAt  time.Time
+	LastSentAt *time.Time
+	StartedAt  time.Time
+	StoppedAt  *time.Time
+}
+
+// StartSequence enrolls a profile in the follow-up sequence. Profiles that
+// are already enrolled keep their current position.
+func (db *DB) StartSequence(profileID int64, profileURL string, firstDueAt time.Time) error {
+	query := `INSERT OR IGNORE INTO follow_up_sequences (profile_id, profile_url, next_step, status, next_due_at)
+	          VALUES (?, ?, 0, ?, ?)`
+	_, err := db.conn.Exec(query, profileID, profileURL, SequenceActive, timestamp(firstDueAt))
+	return err
+}
+
+// GetDueSequences returns active sequences whose next step is due
+func (db *DB) GetDueSequences() ([]*FollowUpSequence, error) {
+	query := `SELECT id, COALESCE(profile_id, 0), profile_url, next_step, status, next_due_at,
+	                 last_sent_at, started_at, stopped_at
+	          FROM follow_up_sequences
+	          WHERE status = ? AND next_due_at <= datetime('now')
+	          ORDER BY next_due_at`
+	rows, err := db.conn.Query(query, SequenceActive)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sequences []*FollowUpSequence
+	for rows.Next() {
+		var seq FollowUpSequence
+		err := rows.Scan(&seq.ID, &seq.ProfileID, &seq.ProfileURL, &seq.NextStep, &seq.Status,
+			&seq.NextDueAt, &seq.LastSentAt, &seq.StartedAt, &seq.StoppedAt)
+		if err != nil {
+			return nil, err
+		}
+		sequences = append(sequences, &seq)
+	}
+
+	return sequences, rows.Err()
+}
+
+// AdvanceSequence records that a step was sent and schedules the next one.
+// Passing a nil nextDueAt marks the sequence completed.
+func (db *DB) AdvanceSequence(profileURL string, nextStep int, nextDueAt *time.Time) error {
+	if nextDueAt == nil {
+		_, err := db.conn.Exec(`UPDATE follow_up_sequences
+			SET next_step = ?, status = ?, last_sent_at = CURRENT_TIMESTAMP, stopped_at = CURRENT_TIMESTAMP
+			WHERE profile_url = ?`, nextStep, SequenceCompleted, profileURL)
+		return err
+	}
+
+	_, err := db.conn.Exec(`UPDATE follow_up_sequences
+		SET next_step = ?, next_due_at = ?, last_sent_at = CURRENT_TIMESTAMP
+		WHERE profile_url = ?`, nextStep, timestamp(*nextDueAt), profileURL)
+	return err
+}
+
+// StopSequence ends an active sequence with the given status, such as
+// SequenceReplied or SequenceSuppressed
+func (db *DB) StopSequence(profileURL, status string) error {
+	_, err := db.conn.Exec(`UPDATE follow_up_sequences
+		SET status = ?, stopped_at = CURRENT_TIMESTAMP
+		WHERE profile_url = ? AND status = ?`, status, profileURL, SequenceActive)
+	return err
+}
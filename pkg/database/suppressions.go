@@ -0,0 +1,65 @@
+package database
+
+import (
+	"time"
+)
+
+// Suppression is an entry on the do-not-contact list
+type Suppression struct {
+	ID         int64
+	ProfileURL string
+	Reason     string
+	CreatedAt  time.Time
+}
+
+// AddSuppression puts a profile on the do-not-contact list and stops any
+// follow-up sequence it is in
+func (db *DB) AddSuppression(profileURL, reason string) error {
+	_, err := db.conn.Exec(`INSERT INTO suppressions (profile_url, reason) VALUES (?, ?)
+		ON CONFLICT(profile_url) DO UPDATE SET reason = excluded.reason`, profileURL, reason)
+	if err != nil {
+		return err
+	}
+	return db.StopSequence(profileURL, SequenceSuppressed)
+}
+
+// RemoveSuppression takes a profile off the do-not-contact list
+func (db *DB) RemoveSuppression(profileURL string) (bool, error) {
+	res, err := db.conn.Exec(`DELETE FROM suppressions WHERE profile_url = ?`, profileURL)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// IsSuppressed reports whether a profile is on the do-not-contact list
+func (db *DB) IsSuppressed(profileURL string) (bool, error) {
+	var count int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM suppressions WHERE profile_url = ?`, profileURL).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetSuppressions returns the do-not-contact list, newest first
+func (db *DB) GetSuppressions() ([]*Suppression, error) {
+	rows, err := db.conn.Query(`SELECT id, profile_url, COALESCE(reason, ''), created_at
+		FROM suppressions ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suppressions []*Suppression
+	for rows.Next() {
+		var s Suppression
+		if err := rows.Scan(&s.ID, &s.ProfileURL, &s.Reason, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		suppressions = append(suppressions, &s)
+	}
+
+	return suppressions, rows.Err()
+}
@@ -0,0 +1,412 @@
+package engagement
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"linkedin-automation/pkg/config"
+	"linkedin-automation/pkg/database"
+	"linkedin-automation/pkg/logger"
+	"linkedin-automation/pkg/metrics"
+	"linkedin-automation/pkg/profileurl"
+	"linkedin-automation/pkg/selectors"
+	stealthpkg "linkedin-automation/pkg/stealth"
+
+	"github.com/go-rod/rod"
+)
+
+var log = logger.Component("engagement")
+
+// Source is the profile source recorded for people found through a post
+const Source = "post-engagement"
+
+// maxLoadMore caps the "show more" clicks per list, so a post with a huge
+// audience cannot keep the collector busy indefinitely when no limit is set
+const maxLoadMore = 50
+
+// postURNRe matches the activity, share or UGC post id of a feed update
+var postURNRe = regexp.MustCompile(`^urn:li:(activity|share|ugcPost):\d+$`)
+
+// reactionTypes maps LinkedIn's internal reaction names to the ones shown in
+// the interface
+var reactionTypes = map[string]string{
+	"like":          "like",
+	"praise":        "celebrate",
+	"appreciation":  "support",
+	"empathy":       "love",
+	"interest":      "insightful",
+	"entertainment": "funny",
+}
+
+// Engagement collects the people who reacted to or commented on posts
+type Engagement struct {
+	config  *config.Config
+	page    *rod.Page
+	stealth *stealthpkg.Stealth
+	db      *database.DB
+}
+
+// Engager is one reaction or comment as shown on a post
+type Engager struct {
+	ProfileURL string `json:"profile_url"`
+	Name       string `json:"name"`
+	Headline   string `json:"headline"`
+	Kind       string `json:"kind"`
+	Reaction   string `json:"reaction,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+}
+
+// CollectSummary counts what was collected from one post
+type CollectSummary struct {
+	PostURL     string `json:"post_url"`
+	Reactions   int    `json:"reactions"`
+	Comments    int    `json:"comments"`
+	NewProfiles int    `json:"new_profiles"`
+	Failed      int    `json:"failed"`
+}
+
+// NewEngagement creates a new engagement collector
+func NewEngagement(cfg *config.Config, page *rod.Page, stealth *stealthpkg.Stealth, db *database.DB) *Engagement {
+	return &Engagement{
+		config:  cfg,
+		page:    page,
+		stealth: stealth,
+		db:      db,
+	}
+}
+
+// CollectPost reads the reactions and comments of a post and records each
+// person as a profile with source "post-engagement", together with the
+// reaction type or comment text and the post it came from. Profiles that
+// already exist keep their source but still get the engagement recorded.
+func (e *Engagement) CollectPost(rawURL string) (*CollectSummary, error) {
+	postURL, err := CanonicalPostURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Collecting post engagement",
+		"post_url", postURL,
+	)
+
+	loadStart := time.Now()
+	if err := e.page.Navigate(postURL); err != nil {
+		return nil, fmt.Errorf("failed to navigate to post: %w", err)
+	}
+
+	e.page.MustWaitLoad()
+	metrics.ObservePageLoad("post", loadStart)
+	e.stealth.ScrollHumanLike(600)
+	e.stealth.RandomDelay()
+
+	summary := &CollectSummary{PostURL: postURL}
+
+	comments, failed := e.readComments()
+	summary.Failed += failed
+	for _, c := range comments {
+		e.save(postURL, c, summary)
+	}
+
+	reactions, failed, err := e.readReactions()
+	summary.Failed += failed
+	if err != nil {
+		log.Warn("Failed to read post reactions",
+			"post_url", postURL,
+			"error", err,
+		)
+	}
+	for _, r := range reactions {
+		e.save(postURL, r, summary)
+	}
+
+	log.Info("Post engagement collected",
+		"post_url", postURL,
+		"reactions", summary.Reactions,
+		"comments", summary.Comments,
+		"new_profiles", summary.NewProfiles,
+		"failed", summary.Failed,
+	)
+
+	return summary, nil
+}
+
+// readComments loads up to the configured number of comments and reads them,
+// returning the engagers and the number of comments that could not be read
+func (e *Engagement) readComments() ([]Engager, int) {
+	limit := e.config.Engagement.MaxComments
+	e.loadMore(e.page, "comment_item", "comments_more_button", limit)
+
+	items, err := selectors.FindAll(e.page, "comment_item")
+	if err != nil || len(items) == 0 {
+		log.Debug("No comments found on post")
+		return nil, 0
+	}
+
+	var engagers []Engager
+	failed := 0
+	for n, item := range items {
+		if limit > 0 && n >= limit {
+			break
+		}
+
+		engager, err := readComment(item)
+		if err != nil {
+			log.Debug("Failed to extract comment",
+				"error", err,
+			)
+			failed++
+			continue
+		}
+		if engager.ProfileURL == "" {
+			continue // posted as a company page
+		}
+		engagers = append(engagers, engager)
+	}
+
+	return engagers, failed
+}
+
+func readComment(item *rod.Element) (Engager, error) {
+	engager := Engager{Kind: database.EngagementComment}
+
+	href, err := profileHref(item, "comment_author_link")
+	if err != nil {
+		return engager, err
+	}
+	if !isProfileURL(href) {
+		return engager, nil
+	}
+
+	engager.ProfileURL = href
+	engager.Name = selectors.Text(item, "comment_author_name")
+	engager.Headline = selectors.Text(item, "comment_author_headline")
+	// Image and GIF comments have no text; the commenter is still a lead
+	engager.Comment = selectors.Text(item, "comment_text")
+	return engager, nil
+}
+
+// readReactions opens the reactions list of the post and reads who reacted
+// and how. A post without reactions has no reactions button, which is not
+// an error.
+func (e *Engagement) readReactions() ([]Engager, int, error) {
+	btn, err := selectors.Find(e.page, "post_reactions_count")
+	if err != nil {
+		log.Debug("No reactions found on post")
+		return nil, 0, nil
+	}
+
+	e.stealth.HumanClick(btn)
+	e.stealth.RandomDelay()
+
+	modal, err := selectors.Wait(e.page, "reactions_modal", 10*time.Second)
+	if err != nil {
+		return nil, 0, fmt.Errorf("reactions list did not open: %w", err)
+	}
+	defer e.closeReactions()
+
+	limit := e.config.Engagement.MaxReactions
+	e.loadMore(modal, "reactor_item", "reactions_more_button", limit)
+
+	items, err := selectors.FindAll(modal, "reactor_item")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to find reactions: %w", err)
+	}
+
+	var engagers []Engager
+	failed := 0
+	for n, item := range items {
+		if limit > 0 && n >= limit {
+			break
+		}
+
+		engager, err := readReaction(item)
+		if err != nil {
+			log.Debug("Failed to extract reaction",
+				"error", err,
+			)
+			failed++
+			continue
+		}
+		if engager.ProfileURL == "" {
+			continue // reacted as a company page
+		}
+		engagers = append(engagers, engager)
+	}
+
+	return engagers, failed, nil
+}
+
+func readReaction(item *rod.Element) (Engager, error) {
+	engager := Engager{Kind: database.EngagementReaction}
+
+	href, err := profileHref(item, "reactor_profile_link")
+	if err != nil {
+		return engager, err
+	}
+	if !isProfileURL(href) {
+		return engager, nil
+	}
+
+	engager.ProfileURL = href
+	engager.Name = selectors.Text(item, "reactor_name")
+	engager.Headline = selectors.Text(item, "reactor_headline")
+	engager.Reaction = database.ReactionUnknown
+
+	if icon, err := selectors.Find(item, "reactor_reaction_icon"); err == nil {
+		for _, attr := range []string{"data-test-reactions-icon-type", "alt"} {
+			if value, err := icon.Attribute(attr); err == nil && value != nil && *value != "" {
+				engager.Reaction = reactionType(*value)
+				break
+			}
+		}
+	}
+
+	return engager, nil
+}
+
+func (e *Engagement) closeReactions() {
+	if btn, err := selectors.Find(e.page, "reactions_modal_close"); err == nil {
+		e.stealth.HumanClick(btn)
+		e.stealth.RandomDelay()
+	}
+}
+
+// loadMore clicks the "show more" button within f until limit items are
+// shown, the button disappears or maxLoadMore clicks have been made
+func (e *Engagement) loadMore(f selectors.Finder, item, button string, limit int) {
+	for i := 0; i < maxLoadMore; i++ {
+		if limit > 0 {
+			items, _ := selectors.FindAll(f, item)
+			if len(items) >= limit {
+				return
+			}
+		}
+
+		btn, err := selectors.Find(f, button)
+		if err != nil {
+			return
+		}
+		e.stealth.HumanClick(btn)
+		e.stealth.RandomDelay()
+	}
+}
+
+// save records an engager as a profile and an engagement of the post
+func (e *Engagement) save(postURL string, engager Engager, summary *CollectSummary) {
+	existing, _ := e.db.GetProfileByURL(engager.ProfileURL)
+
+	err := e.db.AddProfile(&database.Profile{
+		URL:      engager.ProfileURL,
+		Name:     engager.Name,
+		Headline: engager.Headline,
+		Source:   Source,
+		FoundAt:  time.Now(),
+	})
+	if err != nil {
+		log.Warn("Failed to save profile",
+			"url", engager.ProfileURL,
+			"error", err,
+		)
+		summary.Failed++
+		return
+	}
+
+	profileID := int64(0)
+	if existing != nil {
+		profileID = existing.ID
+	} else if profile, _ := e.db.GetProfileByURL(engager.ProfileURL); profile != nil {
+		profileID = profile.ID
+		summary.NewProfiles++
+		metrics.ProfilesFound.Inc(Source)
+	}
+
+	isNew, err := e.db.AddPostEngagement(&database.PostEngagement{
+		PostURL:    postURL,
+		ProfileID:  profileID,
+		ProfileURL: engager.ProfileURL,
+		Kind:       engager.Kind,
+		Reaction:   engager.Reaction,
+		Comment:    engager.Comment,
+	})
+	if err != nil {
+		log.Warn("Failed to record post engagement",
+			"url", engager.ProfileURL,
+			"error", err,
+		)
+		summary.Failed++
+		return
+	}
+	if !isNew {
+		return
+	}
+
+	if engager.Kind == database.EngagementComment {
+		summary.Comments++
+	} else {
+		summary.Reactions++
+	}
+}
+
+// profileHref returns the canonical URL a link within el points to
+func profileHref(el *rod.Element, name string) (string, error) {
+	link, err := selectors.Find(el, name)
+	if err != nil {
+		return "", fmt.Errorf("no profile link found")
+	}
+
+	href, err := link.Attribute("href")
+	if err != nil || href == nil {
+		return "", fmt.Errorf("profile link has no href")
+	}
+
+	return profileurl.Canonical(*href), nil
+}
+
+// isProfileURL reports whether a canonical URL is a member profile rather
+// than, say, a company page
+func isProfileURL(u string) bool {
+	return strings.HasPrefix(u, "https://"+profileurl.Host+"/in/")
+}
+
+// reactionType turns an icon type or alt text such as "PRAISE" or
+// "celebrate" into the reaction's display name
+func reactionType(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if name, ok := reactionTypes[value]; ok {
+		return name
+	}
+	return value
+}
+
+// CanonicalPostURL validates a post URL and strips tracking parameters. It
+// accepts "/posts/<slug>" URLs and feed updates such as
+// "/feed/update/urn:li:activity:<id>/".
+func CanonicalPostURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + strings.TrimPrefix(raw, "//")
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid post URL %q: %w", raw, err)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host != "linkedin.com" && !strings.HasSuffix(host, ".linkedin.com") {
+		return "", fmt.Errorf("%q is not a LinkedIn URL", raw)
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	case len(segments) == 2 && segments[0] == "posts" && segments[1] != "":
+	case len(segments) == 3 && segments[0] == "feed" && segments[1] == "update" && postURNRe.MatchString(segments[2]):
+	default:
+		return "", fmt.Errorf("%q is not a post URL; expected /posts/... or /feed/update/urn:li:activity:...", raw)
+	}
+
+	return "https://" + profileurl.Host + "/" + strings.Join(segments, "/") + "/", nil
+}
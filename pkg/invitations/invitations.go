@@ -0,0 +1,304 @@
+package invitations
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"linkedin-automation/pkg/config"
+	"linkedin-automation/pkg/database"
+	"linkedin-automation/pkg/logger"
+	"linkedin-automation/pkg/metrics"
+	"linkedin-automation/pkg/profileurl"
+	"linkedin-automation/pkg/selectors"
+	stealthpkg "linkedin-automation/pkg/stealth"
+
+	"github.com/go-rod/rod"
+)
+
+var log = logger.Component("invitations")
+
+// Invitations handles received connection invitations
+type Invitations struct {
+	config  *config.Config
+	page    *rod.Page
+	stealth *stealthpkg.Stealth
+	db      *database.DB
+}
+
+// Decision is what triage does with a single invitation
+type Decision string
+
+const (
+	DecisionAccept Decision = "accept"
+	DecisionIgnore Decision = "ignore"
+	DecisionReview Decision = "review"
+)
+
+// Invitation represents a received invitation as shown in the invitation manager
+type Invitation struct {
+	ProfileURL string `json:"profile_url"`
+	Name       string `json:"name"`
+	Headline   string `json:"headline"`
+	Company    string `json:"company"`
+}
+
+// TriageSummary counts the decisions made during a triage run
+type TriageSummary struct {
+	Accepted int `json:"accepted"`
+	Ignored  int `json:"ignored"`
+	Review   int `json:"review"`
+	Failed   int `json:"failed"`
+}
+
+// rules is the compiled form of config.InboundRulesConfig
+type rules struct {
+	acceptHeadline []*regexp.Regexp
+	ignoreCompany  []companyRule
+}
+
+// companyRule matches an ignored company as whole words, so "Meta" does not
+// catch "Metaview" or "metadata"
+type companyRule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// NewInvitations creates a new invitations instance
+func NewInvitations(cfg *config.Config, page *rod.Page, stealth *stealthpkg.Stealth, db *database.DB) *Invitations {
+	return &Invitations{
+		config:  cfg,
+		page:    page,
+		stealth: stealth,
+		db:      db,
+	}
+}
+
+// TriageInbound reads received invitations and accepts, ignores or leaves
+// each one for review according to the configured rules. With dryRun set the
+// decisions are recorded but nothing is clicked.
+func (i *Invitations) TriageInbound(dryRun bool) (*TriageSummary, error) {
+	r, err := compileRules(i.config.Invitations.Inbound)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Starting inbound invitation triage",
+		"dry_run", dryRun,
+	)
+
+	loadStart := time.Now()
+	if err := i.page.Navigate(i.config.LinkedIn.BaseURL + "/mynetwork/invitation-manager/"); err != nil {
+		return nil, fmt.Errorf("failed to navigate to invitation manager: %w", err)
+	}
+
+	i.page.MustWaitLoad()
+	metrics.ObservePageLoad("invitations", loadStart)
+	i.stealth.ScrollHumanLike(800)
+	i.stealth.RandomDelay()
+
+	cards, err := selectors.FindAll(i.page, "invitation_card")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find invitation cards: %w", err)
+	}
+
+	summary := &TriageSummary{}
+	limit := i.config.Invitations.Inbound.MaxInvitations
+
+	for n, card := range cards {
+		if limit > 0 && n >= limit {
+			break
+		}
+
+		inv, err := i.extractInvitation(card)
+		if err != nil {
+			log.Debug("Failed to extract invitation",
+				"error", err,
+			)
+			summary.Failed++
+			continue
+		}
+
+		decision, rule := r.decide(inv)
+
+		if !dryRun && decision != DecisionReview {
+			if err := i.respond(card, decision); err != nil {
+				log.Warn("Failed to respond to invitation",
+					"profile_url", inv.ProfileURL,
+					"decision", string(decision),
+					"error", err,
+				)
+				summary.Failed++
+				continue
+			}
+		}
+
+		if err := i.saveInvitation(inv, decision, rule, !dryRun && decision == DecisionAccept); err != nil {
+			log.Warn("Failed to save invitation",
+				"profile_url", inv.ProfileURL,
+				"error", err,
+			)
+		}
+
+		switch decision {
+		case DecisionAccept:
+			summary.Accepted++
+		case DecisionIgnore:
+			summary.Ignored++
+		default:
+			summary.Review++
+		}
+
+		log.Info("Invitation triaged",
+			"profile_url", inv.ProfileURL,
+			"decision", string(decision),
+			"rule", rule,
+		)
+	}
+
+	log.Info("Inbound invitation triage completed",
+		"accepted", summary.Accepted,
+		"ignored", summary.Ignored,
+		"review", summary.Review,
+		"failed", summary.Failed,
+	)
+
+	return summary, nil
+}
+
+func (i *Invitations) extractInvitation(card *rod.Element) (Invitation, error) {
+	inv := Invitation{}
+
+	linkEl, err := selectors.Find(card, "invitation_profile_link")
+	if err != nil {
+		return inv, fmt.Errorf("no profile link found")
+	}
+
+	href, err := linkEl.Attribute("href")
+	if err != nil || href == nil {
+		return inv, fmt.Errorf("profile link has no href")
+	}
+
+	inv.ProfileURL = profileurl.Canonical(*href)
+
+	inv.Name = selectors.Text(card, "invitation_name")
+	inv.Headline = selectors.Text(card, "invitation_headline")
+
+	inv.Company = companyFromHeadline(inv.Headline)
+
+	return inv, nil
+}
+
+func (i *Invitations) respond(card *rod.Element, decision Decision) error {
+	name := "invitation_accept_button"
+	if decision == DecisionIgnore {
+		name = "invitation_ignore_button"
+	}
+
+	btn, err := selectors.Find(card, name)
+	if err != nil {
+		return fmt.Errorf("%s button not found", decision)
+	}
+
+	i.stealth.HumanClick(btn)
+	i.stealth.RandomDelay()
+
+	return nil
+}
+
+// saveInvitation records the inviter and the decision. Accepted inviters are
+// marked as 1st-degree connections; every inviter is kept out of the connect
+// queue, since they have a pending or answered invitation already.
+func (i *Invitations) saveInvitation(inv Invitation, decision Decision, rule string, accepted bool) error {
+	err := i.db.AddProfile(&database.Profile{
+		URL:      inv.ProfileURL,
+		Name:     inv.Name,
+		Headline: inv.Headline,
+		Company:  inv.Company,
+		Source:   "inbound",
+		FoundAt:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if accepted {
+		if err := i.db.SetConnectionDegree(inv.ProfileURL, 1); err != nil {
+			return err
+		}
+	}
+
+	profileID := int64(0)
+	if profile, _ := i.db.GetProfileByURL(inv.ProfileURL); profile != nil {
+		profileID = profile.ID
+	}
+
+	return i.db.UpsertInboundInvitation(&database.InboundInvitation{
+		ProfileID:  profileID,
+		ProfileURL: inv.ProfileURL,
+		Name:       inv.Name,
+		Headline:   inv.Headline,
+		Company:    inv.Company,
+		Decision:   string(decision),
+		Rule:       rule,
+	})
+}
+
+func compileRules(cfg config.InboundRulesConfig) (*rules, error) {
+	r := &rules{}
+
+	for _, pattern := range cfg.AcceptHeadlinePatterns {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid accept_headline_patterns entry %q: %w", pattern, err)
+		}
+		r.acceptHeadline = append(r.acceptHeadline, re)
+	}
+
+	for _, company := range cfg.IgnoreCompanies {
+		if company = strings.ToLower(strings.TrimSpace(company)); company != "" {
+			r.ignoreCompany = append(r.ignoreCompany, companyRule{
+				name: company,
+				re:   regexp.MustCompile(`(?i)(^|\W)` + regexp.QuoteMeta(company) + `($|\W)`),
+			})
+		}
+	}
+
+	return r, nil
+}
+
+// decide applies the rules to an invitation and returns the decision along
+// with a description of the rule that matched
+func (r *rules) decide(inv Invitation) (Decision, string) {
+	company := strings.ToLower(inv.Company)
+
+	for _, ignored := range r.ignoreCompany {
+		if company == ignored.name || ignored.re.MatchString(inv.Headline) {
+			return DecisionIgnore, "ignore_company:" + ignored.name
+		}
+	}
+
+	for _, re := range r.acceptHeadline {
+		if re.MatchString(inv.Headline) {
+			return DecisionAccept, "accept_headline:" + re.String()[len("(?i)"):]
+		}
+	}
+
+	return DecisionReview, ""
+}
+
+// companyFromHeadline extracts the employer from headlines of the form
+// "Engineer at Acme" or "Engineer @ Acme | Speaker"
+func companyFromHeadline(headline string) string {
+	for _, sep := range []string{" at ", " @ "} {
+		if idx := strings.LastIndex(headline, sep); idx >= 0 {
+			company := headline[idx+len(sep):]
+			if cut := strings.IndexAny(company, "|,·"); cut >= 0 {
+				company = company[:cut]
+			}
+			return strings.TrimSpace(company)
+		}
+	}
+	return ""
+}
@@ -1,214 +1,366 @@
 package logger
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
-// Logger represents a structured logger
-type Logger struct {
-	level  Level
-	format Format
-	writer io.Writer
-	logger *log.Logger
-}
-
-type Level int
-
+// Keys of the attributes added to every log line
 const (
-	DebugLevel Level = iota
-	InfoLevel
-	WarnLevel
-	ErrorLevel
+	ComponentKey = "component"
+	RunIDKey     = "run_id"
+	CampaignKey  = "campaign"
 )
 
-type Format int
+// DefaultCampaign is logged when no campaign is configured
+const DefaultCampaign = "default"
 
+// Limits of file sinks that leave them at zero, so a log file configured
+// without them still cannot grow forever
 const (
-	TextFormat Format = iota
-	JSONFormat
+	DefaultMaxSizeMB  = 100
+	DefaultMaxBackups = 7
 )
 
-var globalLogger *Logger
+// Options configures the logger
+type Options struct {
+	Level  string // debug, info, warn or error
+	Format string // json or text, used when Sinks is empty
+	Output string // stdout or a file path, used when Sinks is empty
+	// Sinks are written to at the same time, e.g. text on stdout and JSON
+	// to a rotated file
+	Sinks []Sink
+	// Components overrides Level for loggers created with Component, e.g.
+	// {"search": "debug"}
+	Components map[string]string
+	// Campaign is added to every line; DefaultCampaign when empty
+	Campaign string
+}
 
-// NewLogger creates a new logger instance and sets it as global
-func NewLogger(levelStr, formatStr, outputStr string) (*Logger, error) {
-	level, err := parseLevel(levelStr)
+// Sink is one destination for log lines
+type Sink struct {
+	Output string // stdout, stderr or a file path
+	Format string // json or text
+	// Level is this sink's level, which may be lower or higher than
+	// Options.Level; empty uses Options.Level
+	Level string
+	// File outputs are rotated once they reach MaxSizeMB or are MaxAge old.
+	// The newest MaxBackups archives are kept, gzipped when Compress is set.
+	// Zero MaxSizeMB and MaxBackups use DefaultMaxSizeMB and
+	// DefaultMaxBackups and a negative value disables them; zero MaxAge
+	// disables rotation by age.
+	MaxSizeMB  int
+	MaxAge     time.Duration
+	MaxBackups int
+	Compress   bool
+}
+
+// Logger owns the outputs of the configured logger
+type Logger struct {
+	closers []io.Closer
+	runID   string
+}
+
+// state is what Init configures. Loggers hold the root handler, which reads
+// the current state on every call, so component loggers created in package
+// variables before Init still pick up the configuration.
+type state struct {
+	sinks      []sink
+	components map[string]slog.Level
+	minLevel   slog.Level // the lowest sink level, below which nothing is written
+}
+
+// sink is an output with its level
+type sink struct {
+	handler slog.Handler // with run_id and campaign already attached
+	level   slog.Level
+}
+
+var current atomic.Pointer[state]
+
+// root is the handler behind every logger this package returns
+var root = &handler{}
+
+func init() {
+	// Until Init runs, log info and above as text to stderr
+	current.Store(&state{
+		sinks:    []sink{{handler: slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}), level: slog.LevelInfo}},
+		minLevel: slog.LevelInfo,
+	})
+}
+
+// Init configures the logger used by the package-level functions and every
+// component logger, and tags all lines with a new run id and the campaign
+func Init(opts Options) (*Logger, error) {
+	level, err := parseLevel(opts.Level)
 	if err != nil {
 		return nil, err
 	}
 
-	format, err := parseFormat(formatStr)
-	if err != nil {
-		return nil, err
+	components := make(map[string]slog.Level, len(opts.Components))
+	for component, s := range opts.Components {
+		l, err := parseLevel(s)
+		if err != nil {
+			return nil, fmt.Errorf("component %s: %w", component, err)
+		}
+		components[component] = l
 	}
 
-	writer, err := getWriter(outputStr)
-	if err != nil {
-		return nil, err
+	configured := opts.Sinks
+	if len(configured) == 0 {
+		configured = []Sink{{Output: opts.Output, Format: opts.Format}}
 	}
 
-	l := &Logger{
-		level:  level,
-		format: format,
-		writer: writer,
-		logger: log.New(writer, "", 0),
+	campaign := opts.Campaign
+	if campaign == "" {
+		campaign = DefaultCampaign
+	}
+
+	l := &Logger{runID: newRunID()}
+	attrs := []slog.Attr{slog.String(RunIDKey, l.runID), slog.String(CampaignKey, campaign)}
+	st := &state{components: components, minLevel: level}
+	for i, cfg := range configured {
+		sinkLevel := level
+		if cfg.Level != "" {
+			if sinkLevel, err = parseLevel(cfg.Level); err != nil {
+				l.Close()
+				return nil, fmt.Errorf("log output %s: %w", cfg.Output, err)
+			}
+		}
+
+		h, closer, err := openSink(cfg)
+		if err != nil {
+			l.Close()
+			return nil, fmt.Errorf("log output %s: %w", cfg.Output, err)
+		}
+		if closer != nil {
+			l.closers = append(l.closers, closer)
+		}
+
+		st.sinks = append(st.sinks, sink{handler: h.WithAttrs(attrs), level: sinkLevel})
+		if i == 0 || sinkLevel < st.minLevel {
+			st.minLevel = sinkLevel
+		}
 	}
 
-	globalLogger = l
+	current.Store(st)
 	return l, nil
 }
 
-// GetLogger returns the global logger
-func GetLogger() *Logger {
-	return globalLogger
+// RunID returns the id that tags every line of this run
+func (l *Logger) RunID() string {
+	return l.runID
 }
 
-// Close closes the logger if it has a file writer
+// Close closes the file outputs, waiting for archives to be compressed
 func (l *Logger) Close() error {
-	if closer, ok := l.writer.(io.Closer); ok {
-		return closer.Close()
+	var first error
+	for _, c := range l.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
 	}
-	return nil
+	return first
 }
 
-// Debug logs a debug message
-func (l *Logger) Debug(message string, fields map[string]interface{}) {
-	l.log(DebugLevel, message, fields)
+// openSink creates the handler for a sink, and the file to close with the
+// logger for file outputs
+func openSink(sink Sink) (slog.Handler, io.Closer, error) {
+	// Levels are filtered by the root handler, which knows each record's
+	// component, so the output handler lets everything through
+	handlerOpts := &slog.HandlerOptions{Level: slog.LevelDebug}
+
+	format := strings.ToLower(sink.Format)
+	if format != "json" && format != "text" {
+		return nil, nil, fmt.Errorf("invalid log format: %s", sink.Format)
+	}
+
+	var w io.Writer
+	var closer io.Closer
+	switch sink.Output {
+	case "", "stdout":
+		w = os.Stdout
+	case "stderr":
+		w = os.Stderr
+	default:
+		maxSize := fileLimit(sink.MaxSizeMB, DefaultMaxSizeMB)
+		maxBackups := fileLimit(sink.MaxBackups, DefaultMaxBackups)
+		f, err := newRotatingFile(sink.Output, int64(maxSize)<<20, sink.MaxAge, maxBackups, sink.Compress)
+		if err != nil {
+			return nil, nil, err
+		}
+		w, closer = f, f
+	}
+
+	if format == "json" {
+		return slog.NewJSONHandler(w, handlerOpts), closer, nil
+	}
+	return slog.NewTextHandler(w, handlerOpts), closer, nil
 }
 
-// Info logs an info message
-func (l *Logger) Info(message string, fields map[string]interface{}) {
-	l.log(InfoLevel, message, fields)
+// fileLimit applies the default to a zero limit; a negative one disables it
+func fileLimit(v, def int) int {
+	switch {
+	case v == 0:
+		return def
+	case v < 0:
+		return 0
+	default:
+		return v
+	}
 }
 
-// Warn logs a warning message
-func (l *Logger) Warn(message string, fields map[string]interface{}) {
-	l.log(WarnLevel, message, fields)
+// With returns a logger that adds args, key-value pairs as in slog, to every
+// line
+func With(args ...any) *slog.Logger {
+	return slog.New(root).With(args...)
 }
 
-// Error logs an error message
-func (l *Logger) Error(message string, fields map[string]interface{}) {
-	l.log(ErrorLevel, message, fields)
+// Component returns a logger for a package such as "search" or "auth". Its
+// lines carry a component attribute and are filtered by the component's
+// level when one is configured.
+func Component(name string) *slog.Logger {
+	return With(ComponentKey, name)
 }
 
-// Package-level helpers for convenience
-func Debug(message string, fields map[string]interface{}) {
-	if globalLogger != nil {
-		globalLogger.Debug(message, fields)
-	}
+// Debug logs a debug message with key-value pairs
+func Debug(message string, args ...any) {
+	logAt(slog.LevelDebug, message, args)
 }
 
-func Info(message string, fields map[string]interface{}) {
-	if globalLogger != nil {
-		globalLogger.Info(message, fields)
-	}
+// Info logs an info message with key-value pairs
+func Info(message string, args ...any) {
+	logAt(slog.LevelInfo, message, args)
 }
 
-func Warn(message string, fields map[string]interface{}) {
-	if globalLogger != nil {
-		globalLogger.Warn(message, fields)
-	}
+// Warn logs a warning message with key-value pairs
+func Warn(message string, args ...any) {
+	logAt(slog.LevelWarn, message, args)
 }
 
-func Error(message string, fields map[string]interface{}) {
-	if globalLogger != nil {
-		globalLogger.Error(message, fields)
-	}
+// Error logs an error message with key-value pairs
+func Error(message string, args ...any) {
+	logAt(slog.LevelError, message, args)
 }
 
-func (l *Logger) log(level Level, message string, fields map[string]interface{}) {
-	if level < l.level {
+func logAt(level slog.Level, message string, args []any) {
+	ctx := context.Background()
+	if !root.Enabled(ctx, level) {
 		return
 	}
+	r := slog.NewRecord(time.Now(), level, message, 0)
+	r.Add(args...)
+	_ = root.Handle(ctx, r)
+}
+
+// handler applies the current state. It records WithAttrs and WithGroup
+// calls and replays them onto each sink that takes the record. A component
+// level overrides every sink's level for that component.
+type handler struct {
+	component string
+	ops       []op
+}
+
+// op is one WithAttrs (group empty) or WithGroup call
+type op struct {
+	attrs []slog.Attr
+	group string
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	s := current.Load()
+	if l, ok := h.componentLevel(s); ok {
+		return level >= l
+	}
+	return level >= s.minLevel
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	s := current.Load()
+	override, overridden := h.componentLevel(s)
 
-	entry := LogEntry{
-		Time:    time.Now().UTC().Format(time.RFC3339),
-		Level:   level.String(),
-		Message: message,
-		Fields:  fields,
-	}
-
-	var output string
-	switch l.format {
-	case JSONFormat:
-		data, _ := json.Marshal(entry)
-		output = string(data)
-	case TextFormat:
-		output = fmt.Sprintf("[%s] %s: %s", entry.Time, entry.Level, entry.Message)
-		if len(fields) > 0 {
-			fieldStrs := make([]string, 0, len(fields))
-			for k, v := range fields {
-				fieldStrs = append(fieldStrs, fmt.Sprintf("%s=%v", k, v))
+	var first error
+	for _, sk := range s.sinks {
+		threshold := sk.level
+		if overridden {
+			threshold = override
+		}
+		if r.Level < threshold {
+			continue
+		}
+
+		out := sk.handler
+		for _, o := range h.ops {
+			if o.group != "" {
+				out = out.WithGroup(o.group)
+			} else {
+				out = out.WithAttrs(o.attrs)
 			}
-			output += " " + strings.Join(fieldStrs, " ")
+		}
+		if err := out.Handle(ctx, r.Clone()); err != nil && first == nil {
+			first = err
 		}
 	}
+	return first
+}
 
-	l.logger.Println(output)
+// componentLevel returns the configured level of the handler's component
+func (h *handler) componentLevel(s *state) (slog.Level, bool) {
+	if h.component == "" {
+		return 0, false
+	}
+	l, ok := s.components[h.component]
+	return l, ok
 }
 
-type LogEntry struct {
-	Time    string                 `json:"time"`
-	Level   string                 `json:"level"`
-	Message string                 `json:"message"`
-	Fields  map[string]interface{} `json:"fields,omitempty"`
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	child := &handler{component: h.component, ops: append(h.ops[:len(h.ops):len(h.ops)], op{attrs: attrs})}
+	for _, a := range attrs {
+		if a.Key == ComponentKey {
+			child.component = a.Value.String()
+		}
+	}
+	return child
 }
 
-func (l Level) String() string {
-	switch l {
-	case DebugLevel:
-		return "DEBUG"
-	case InfoLevel:
-		return "INFO"
-	case WarnLevel:
-		return "WARN"
-	case ErrorLevel:
-		return "ERROR"
-	default:
-		return "UNKNOWN"
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
 	}
+	return &handler{component: h.component, ops: append(h.ops[:len(h.ops):len(h.ops)], op{group: name})}
+}
+
+// newRunID returns a sortable id for this process, its start time and a
+// random suffix
+func newRunID() string {
+	b := make([]byte, 3)
+	_, _ = rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(b)
 }
 
-func parseLevel(s string) (Level, error) {
+func parseLevel(s string) (slog.Level, error) {
 	switch strings.ToLower(s) {
 	case "debug":
-		return DebugLevel, nil
+		return slog.LevelDebug, nil
 	case "info":
-		return InfoLevel, nil
+		return slog.LevelInfo, nil
 	case "warn", "warning":
-		return WarnLevel, nil
+		return slog.LevelWarn, nil
 	case "error":
-		return ErrorLevel, nil
-	default:
-		return InfoLevel, fmt.Errorf("invalid log level: %s", s)
-	}
-}
-
-func parseFormat(s string) (Format, error) {
-	switch strings.ToLower(s) {
-	case "json":
-		return JSONFormat, nil
-	case "text":
-		return TextFormat, nil
+		return slog.LevelError, nil
 	default:
-		return TextFormat, fmt.Errorf("invalid log format: %s", s)
-	}
-}
-
-func getWriter(output string) (io.Writer, error) {
-	if output == "stdout" {
-		return os.Stdout, nil
-	}
-
-	file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		return nil, err
+		return slog.LevelInfo, fmt.Errorf("invalid log level: %s", s)
 	}
-	return file, nil
 }
@@ -0,0 +1,254 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat names archives so they sort by age
+const backupTimeFormat = "20060102T150405.000"
+
+// rotatingFile is a log file that is moved aside once it reaches maxSize
+// bytes or has been written to for maxAge. Archives are optionally gzipped
+// and only the newest maxBackups are kept. Zero values disable each limit.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+
+	// mill serialises compression and pruning, which run in the background
+	// so a rotation does not stall logging
+	mill sync.Mutex
+	wg   sync.WaitGroup
+}
+
+func newRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int, compress bool) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+		compress:   compress,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens or creates the log file. An existing file counts as opened
+// when it was last written, so a file left by an earlier run still rotates
+// on age.
+func (r *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file = file
+	r.size = info.Size()
+	r.openedAt = time.Now()
+	if r.size > 0 {
+		r.openedAt = info.ModTime()
+	}
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if r.size > 0 && r.due(int64(len(p))) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// due reports whether writing n more bytes needs a new file
+func (r *rotatingFile) due(n int64) bool {
+	if r.maxSize > 0 && r.size+n > r.maxSize {
+		return true
+	}
+	return r.maxAge > 0 && time.Since(r.openedAt) >= r.maxAge
+}
+
+// rotate moves the current file aside, starts a new one and hands the
+// archive to the background compression and pruning
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+
+	ext := filepath.Ext(r.path)
+	backup := strings.TrimSuffix(r.path, ext) + "-" + time.Now().UTC().Format(backupTimeFormat) + ext
+	if err := os.Rename(r.path, backup); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		if err := r.millRun(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to clean up log archives of %s: %v\n", r.path, err)
+		}
+	}()
+
+	return nil
+}
+
+// millRun removes all but the newest maxBackups archives and compresses the
+// rest. Each run looks at every archive, so runs started by quick
+// successive rotations need not finish in order.
+func (r *rotatingFile) millRun() error {
+	r.mill.Lock()
+	defer r.mill.Unlock()
+
+	backups, err := r.backups()
+	if err != nil {
+		return err
+	}
+
+	if r.maxBackups > 0 && len(backups) > r.maxBackups {
+		for _, old := range backups[:len(backups)-r.maxBackups] {
+			if err := os.Remove(old); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		backups = backups[len(backups)-r.maxBackups:]
+	}
+
+	if !r.compress {
+		return nil
+	}
+	for _, backup := range backups {
+		if strings.HasSuffix(backup, ".gz") {
+			continue
+		}
+		if err := compressFile(backup); err != nil {
+			return fmt.Errorf("failed to compress %s: %w", backup, err)
+		}
+	}
+	return nil
+}
+
+// backups lists the archives of the log file, oldest first
+func (r *rotatingFile) backups() ([]string, error) {
+	ext := filepath.Ext(r.path)
+	prefix := filepath.Base(strings.TrimSuffix(r.path, ext)) + "-"
+
+	entries, err := os.ReadDir(filepath.Dir(r.path))
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ext)[len(prefix):]
+		if _, err := time.Parse(backupTimeFormat, stamp); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(filepath.Dir(r.path), name))
+	}
+
+	// The timestamps sort chronologically; ignore the .gz suffix so a
+	// compressed and a plain archive still compare by time
+	sort.Slice(backups, func(i, j int) bool {
+		return strings.TrimSuffix(backups[i], ".gz") < strings.TrimSuffix(backups[j], ".gz")
+	})
+	return backups, nil
+}
+
+// Close waits for background compression and closes the file
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.wg.Wait()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// compressFile gzips path to path.gz and removes the original
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
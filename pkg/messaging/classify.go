@@ -0,0 +1,78 @@
+package messaging
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"linkedin-automation/pkg/config"
+)
+
+// Reply labels
+const (
+	LabelInterested    = "interested"
+	LabelNotInterested = "not_interested"
+	LabelOutOfOffice   = "out_of_office"
+	LabelReferral      = "referral"
+	LabelUnsubscribe   = "unsubscribe"
+)
+
+var replyLabels = map[string]bool{
+	LabelInterested:    true,
+	LabelNotInterested: true,
+	LabelOutOfOffice:   true,
+	LabelReferral:      true,
+	LabelUnsubscribe:   true,
+}
+
+// replyRule is the compiled form of config.ReplyRuleConfig
+type replyRule struct {
+	label    string
+	patterns []*regexp.Regexp
+}
+
+// classifier labels reply bodies with the first matching rule
+type classifier struct {
+	rules []replyRule
+}
+
+func compileReplyRules(cfg []config.ReplyRuleConfig) (*classifier, error) {
+	c := &classifier{}
+
+	for _, rc := range cfg {
+		if !replyLabels[rc.Label] {
+			return nil, fmt.Errorf("unknown reply label %q", rc.Label)
+		}
+
+		rule := replyRule{label: rc.Label}
+		for _, keyword := range rc.Keywords {
+			if keyword = strings.TrimSpace(keyword); keyword != "" {
+				rule.patterns = append(rule.patterns, regexp.MustCompile(`(?i)\b`+regexp.QuoteMeta(keyword)+`\b`))
+			}
+		}
+		for _, pattern := range rc.Patterns {
+			re, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid reply_rules pattern %q: %w", pattern, err)
+			}
+			rule.patterns = append(rule.patterns, re)
+		}
+
+		c.rules = append(c.rules, rule)
+	}
+
+	return c, nil
+}
+
+// classify returns the label of the first rule that matches body, or an
+// empty string
+func (c *classifier) classify(body string) string {
+	for _, rule := range c.rules {
+		for _, re := range rule.patterns {
+			if re.MatchString(body) {
+				return rule.label
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,348 @@
+package messaging
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"linkedin-automation/pkg/artifacts"
+	"linkedin-automation/pkg/database"
+	"linkedin-automation/pkg/metrics"
+	"linkedin-automation/pkg/profileurl"
+	"linkedin-automation/pkg/selectors"
+)
+
+// defaultMaxThreads is used when inbox_sync.max_threads is not set
+const defaultMaxThreads = 20
+
+// SyncSummary counts what an inbox sync read and stored
+type SyncSummary struct {
+	Threads     int `json:"threads"`
+	Matched     int `json:"matched"`
+	NewMessages int `json:"new_messages"`
+	NewReplies  int `json:"new_replies"`
+	Labeled     int `json:"labeled"`
+	Suppressed  int `json:"suppressed"`
+}
+
+// SyncInbox reads the most recent conversation threads, matches each one to
+// a stored profile by URL and records every message with its direction.
+// Follow-up sequences of profiles that replied are stopped, new replies are
+// labelled with the reply rules and unsubscribe requests are suppressed.
+func (m *Messaging) SyncInbox() (*SyncSummary, error) {
+	classifier, err := compileReplyRules(m.config.Messaging.ReplyRules)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Starting inbox sync")
+
+	loadStart := time.Now()
+	if err := m.page.Navigate(m.config.LinkedIn.BaseURL + "/messaging/"); err != nil {
+		return nil, fmt.Errorf("failed to navigate to inbox: %w", err)
+	}
+
+	m.page.MustWaitLoad()
+	metrics.ObservePageLoad("inbox", loadStart)
+	m.stealth.RandomDelay()
+
+	threadURLs, err := m.threadURLs()
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &SyncSummary{}
+	for _, threadURL := range threadURLs {
+		if err := m.syncThread(threadURL, classifier, summary); err != nil {
+			log.Warn("Failed to sync conversation",
+				"thread_url", threadURL,
+				"error", err,
+				artifacts.Capture(m.page, "inbox", err),
+			)
+			continue
+		}
+		summary.Threads++
+		m.stealth.RandomDelay()
+	}
+
+	log.Info("Inbox sync completed",
+		"threads", summary.Threads,
+		"matched", summary.Matched,
+		"new_messages", summary.NewMessages,
+		"new_replies", summary.NewReplies,
+		"labeled", summary.Labeled,
+		"suppressed", summary.Suppressed,
+	)
+
+	return summary, nil
+}
+
+// threadURLs collects the links of the most recent threads in the inbox list
+func (m *Messaging) threadURLs() ([]string, error) {
+	links, err := selectors.FindAll(m.page, "inbox_thread_link")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find conversation list: %w", err)
+	}
+
+	limit := m.config.Messaging.InboxSync.MaxThreads
+	if limit <= 0 {
+		limit = defaultMaxThreads
+	}
+
+	var urls []string
+	for _, link := range links {
+		if len(urls) >= limit {
+			break
+		}
+		href, err := link.Attribute("href")
+		if err != nil || href == nil {
+			continue
+		}
+		urls = append(urls, m.absoluteURL(*href))
+	}
+
+	return urls, nil
+}
+
+func (m *Messaging) syncThread(threadURL string, classifier *classifier, summary *SyncSummary) error {
+	threadID := threadIDFromURL(threadURL)
+	if threadID == "" {
+		return fmt.Errorf("unrecognised thread URL")
+	}
+
+	loadStart := time.Now()
+	if err := m.page.Navigate(threadURL); err != nil {
+		return fmt.Errorf("failed to open thread: %w", err)
+	}
+	m.page.MustWaitLoad()
+	metrics.ObservePageLoad("thread", loadStart)
+
+	conv := &database.Conversation{
+		ThreadID:        threadID,
+		ParticipantName: selectors.Text(m.page, "thread_participant_name"),
+	}
+
+	if link, err := selectors.Find(m.page, "thread_profile_link"); err == nil {
+		if href, _ := link.Attribute("href"); href != nil {
+			conv.ProfileURL = profileurl.Canonical(*href)
+		}
+	}
+
+	if profile := m.matchProfile(conv.ProfileURL); profile != nil {
+		conv.ProfileID = profile.ID
+		conv.ProfileURL = profile.URL
+		summary.Matched++
+	}
+
+	convID, err := m.db.UpsertConversation(conv)
+	if err != nil {
+		return fmt.Errorf("failed to save conversation: %w", err)
+	}
+
+	events, err := selectors.FindAll(m.page, "thread_event")
+	if err != nil {
+		return fmt.Errorf("failed to read messages: %w", err)
+	}
+
+	now := time.Now()
+	var heading, clock, sender, label string
+	replied, unsubscribed := false, false
+
+	for _, event := range events {
+		// Day headings and sender/time lines only appear on the first event
+		// of a group, so carry them forward
+		if h := selectors.Text(event, "thread_day_heading"); h != "" {
+			heading = h
+		}
+		if c := selectors.Text(event, "thread_timestamp"); c != "" {
+			clock = c
+		}
+		if n := selectors.Text(event, "thread_sender_name"); n != "" {
+			sender = n
+		}
+
+		items, err := selectors.FindAll(event, "thread_message")
+		if err != nil {
+			continue
+		}
+
+		for _, item := range items {
+			urn, _ := item.Attribute("data-event-urn")
+			if urn == nil || *urn == "" {
+				continue
+			}
+
+			direction := database.DirectionOutbound
+			if selectors.Matches(item, "message_inbound_item") {
+				direction = database.DirectionInbound
+				replied = true
+			}
+
+			body := selectors.Text(item, "thread_message_body")
+			isNew, err := m.db.AddConversationMessage(&database.ConversationMessage{
+				ConversationID: convID,
+				MessageURN:     *urn,
+				Direction:      direction,
+				Sender:         sender,
+				Body:           body,
+				SentAt:         parseMessageTime(heading, clock, now),
+			})
+			if err != nil {
+				log.Debug("Failed to save conversation message",
+					"thread_id", threadID,
+					"error", err,
+				)
+				continue
+			}
+
+			if isNew {
+				summary.NewMessages++
+				if direction == database.DirectionInbound {
+					summary.NewReplies++
+
+					// Messages are listed oldest first, so the latest reply
+					// decides the label
+					if l := classifier.classify(body); l != "" {
+						label = l
+						unsubscribed = unsubscribed || l == LabelUnsubscribe
+					}
+				}
+			}
+		}
+	}
+
+	if label != "" {
+		if err := m.db.SetConversationLabel(convID, label); err != nil {
+			log.Warn("Failed to save reply label",
+				"thread_id", threadID,
+				"error", err,
+			)
+		} else {
+			summary.Labeled++
+			log.Info("Reply classified",
+				"thread_id", threadID,
+				"profile_url", conv.ProfileURL,
+				"label", label,
+			)
+		}
+	}
+
+	if unsubscribed {
+		m.suppressUnsubscribed(conv, summary)
+	}
+
+	if replied && conv.ProfileURL != "" {
+		if err := m.db.StopSequence(conv.ProfileURL, database.SequenceReplied); err != nil {
+			log.Warn("Failed to stop sequence",
+				"profile_url", conv.ProfileURL,
+				"error", err,
+			)
+		}
+	}
+
+	return nil
+}
+
+// suppressUnsubscribed puts a participant who asked to stop on the
+// do-not-contact list
+func (m *Messaging) suppressUnsubscribed(conv *database.Conversation, summary *SyncSummary) {
+	if conv.ProfileURL == "" {
+		log.Warn("Unsubscribe request from unknown profile, not suppressed",
+			"thread_id", conv.ThreadID,
+			"participant", conv.ParticipantName,
+		)
+		return
+	}
+
+	if err := m.db.AddSuppression(conv.ProfileURL, "reply: "+LabelUnsubscribe); err != nil {
+		log.Warn("Failed to suppress profile",
+			"profile_url", conv.ProfileURL,
+			"error", err,
+		)
+		return
+	}
+
+	summary.Suppressed++
+	log.Info("Profile suppressed after unsubscribe reply",
+		"profile_url", conv.ProfileURL,
+	)
+}
+
+// matchProfile looks up the stored profile for a participant link
+func (m *Messaging) matchProfile(profileURL string) *database.Profile {
+	if profileURL == "" {
+		return nil
+	}
+
+	profile, err := m.db.GetProfileByURL(profileurl.Canonical(profileURL))
+	if err != nil {
+		return nil
+	}
+	return profile
+}
+
+// absoluteURL resolves hrefs such as "/messaging/thread/..." against the base URL
+func (m *Messaging) absoluteURL(href string) string {
+	if strings.HasPrefix(href, "/") {
+		return m.config.LinkedIn.BaseURL + href
+	}
+	return href
+}
+
+// threadIDFromURL extracts the id from ".../messaging/thread/<id>/"
+func threadIDFromURL(threadURL string) string {
+	u, err := url.Parse(threadURL)
+	if err != nil {
+		return ""
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i, part := range parts {
+		if part == "thread" && i+1 < len(parts) {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
+// parseMessageTime combines a day heading such as "TODAY", "MONDAY",
+// "Jan 5" or "Dec 28, 2024" with a clock time such as "10:32 AM". Parts that
+// cannot be read fall back to now.
+func parseMessageTime(heading, clock string, now time.Time) time.Time {
+	day := now
+	heading = strings.TrimSpace(heading)
+
+	switch upper := strings.ToUpper(heading); upper {
+	case "", "TODAY":
+	case "YESTERDAY":
+		day = now.AddDate(0, 0, -1)
+	default:
+		parsed := false
+		for offset := 1; offset <= 7; offset++ {
+			d := now.AddDate(0, 0, -offset)
+			if strings.ToUpper(d.Weekday().String()) == upper {
+				day, parsed = d, true
+				break
+			}
+		}
+
+		if !parsed {
+			if t, err := time.ParseInLocation("Jan 2, 2006", heading, now.Location()); err == nil {
+				day = t
+			} else if t, err := time.ParseInLocation("Jan 2", heading, now.Location()); err == nil {
+				day = time.Date(now.Year(), t.Month(), t.Day(), 0, 0, 0, 0, now.Location())
+				if day.After(now) {
+					day = day.AddDate(-1, 0, 0)
+				}
+			}
+		}
+	}
+
+	hour, minute := now.Hour(), now.Minute()
+	if t, err := time.Parse("3:04 PM", strings.ToUpper(strings.TrimSpace(clock))); err == nil {
+		hour, minute = t.Hour(), t.Minute()
+	}
+
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, now.Location())
+}
@@ -1,55 +1,123 @@
 package messaging
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
 	"time"
 
 	"github.com/go-rod/rod"
+	"linkedin-automation/pkg/approval"
+	"linkedin-automation/pkg/artifacts"
 	"linkedin-automation/pkg/config"
 	"linkedin-automation/pkg/database"
 	"linkedin-automation/pkg/logger"
+	"linkedin-automation/pkg/metrics"
+	"linkedin-automation/pkg/profileurl"
+	"linkedin-automation/pkg/retry"
+	"linkedin-automation/pkg/selectors"
 	"linkedin-automation/pkg/stealth"
+	"linkedin-automation/pkg/templates"
 )
 
+var log = logger.Component("messaging")
+
+var (
+	// ErrAlreadySent is returned by SendMessage when the profile was already messaged
+	ErrAlreadySent = errors.New("message already sent")
+
+	// ErrReplied is returned when the prospect has answered in the thread,
+	// which ends their follow-up sequence
+	ErrReplied = errors.New("prospect has replied")
+
+	// ErrNotDelivered is returned when a sent message does not show up in the thread
+	ErrNotDelivered = errors.New("message not found in thread after sending")
+
+	// ErrDailyLimit is returned instead of sending once messaging.daily_limit
+	// messages went out today
+	ErrDailyLimit = errors.New("daily message limit reached")
+)
+
+// verifyTimeout is how long to wait for a sent message to appear in the thread
+const verifyTimeout = 10 * time.Second
+
 // Messaging handles LinkedIn messaging
 type Messaging struct {
-	config  *config.Config
-	page    *rod.Page
-	stealth *stealth.Stealth
-	db      *database.DB
+	config   *config.Config
+	page     *rod.Page
+	stealth  *stealth.Stealth
+	db       *database.DB
+	retries  *retry.Tracker
+	approval *approval.Gate
+	picker   *templates.Picker
 }
 
 // NewMessaging creates a new messaging instance
 func NewMessaging(cfg *config.Config, page *rod.Page, st *stealth.Stealth, db *database.DB) *Messaging {
 	return &Messaging{
-		config:  cfg,
-		page:    page,
-		stealth: st,
-		db:      db,
+		config:   cfg,
+		page:     page,
+		stealth:  st,
+		db:       db,
+		retries:  retry.NewTracker(cfg, db, retry.ActionMessage),
+		approval: approval.NewGate(cfg, db, retry.ActionMessage),
+		picker:   templates.NewPicker(cfg.Messaging.RandomSeed),
 	}
 }
 
 // SendMessage sends a message to a profile
 func (m *Messaging) SendMessage(profileURL string, message string) error {
+	profileURL = profileurl.Canonical(profileURL)
+
 	// Check if already sent
 	hasMessage, err := m.db.HasMessage(profileURL)
 	if err != nil {
-		logger.Warn("Failed to check message status", map[string]interface{}{"error": err.Error()})
+		log.Warn("Failed to check message status", "error", err)
 	}
 	if hasMessage {
-		logger.Info("Message already sent", map[string]interface{}{"profile_url": profileURL})
-		return fmt.Errorf("message already sent")
+		log.Info("Message already sent", "profile_url", profileURL)
+		return ErrAlreadySent
+	}
+
+	return m.deliver(&database.Message{ProfileURL: profileURL, Content: message, TemplateIndex: -1}, false)
+}
+
+// deliver opens the conversation with a profile, types the message and
+// records it. With checkReply set it returns ErrReplied instead of sending
+// when the thread already contains a message from the prospect.
+func (m *Messaging) deliver(msg *database.Message, checkReply bool) (err error) {
+	profileURL := msg.ProfileURL
+	message := msg.Content
+
+	if m.dailyLimitReached() {
+		return ErrDailyLimit
 	}
 
-	logger.Info("Sending message", map[string]interface{}{"profile_url": profileURL})
+	actionStart := time.Now()
+	defer func() {
+		metrics.ObserveAction("message", actionStart)
+		switch {
+		case err == nil:
+			metrics.MessagesSent.Inc()
+		case !errors.Is(err, ErrReplied):
+			metrics.MessagesFailed.Inc()
+		}
+	}()
+
+	log.Info("Sending message",
+		"profile_url", profileURL,
+		"sequence_step", msg.SequenceStep,
+	)
 
 	// Navigate to profile
+	loadStart := time.Now()
 	if err := m.page.Navigate(profileURL); err != nil {
 		return fmt.Errorf("failed to navigate to profile: %w", err)
 	}
 
 	m.page.MustWaitLoad()
+	metrics.ObservePageLoad("profile", loadStart)
 	m.stealth.RandomDelay()
 
 	// Find message button
@@ -74,6 +142,10 @@ func (m *Messaging) SendMessage(profileURL string, message string) error {
 	// Wait for message modal/chat to open
 	time.Sleep(2 * time.Second)
 
+	if checkReply && m.threadHasReply() {
+		return ErrReplied
+	}
+
 	// Find message input
 	messageInput, err := m.findMessageInput()
 	if err != nil {
@@ -109,10 +181,9 @@ func (m *Messaging) SendMessage(profileURL string, message string) error {
 
 	m.stealth.RandomHover(sendButton)
 	sendButton.MustClick()
-	m.stealth.RandomDelay()
 
-	// Wait for message to send
-	time.Sleep(1 * time.Second)
+	// Confirm the message went out before recording it as sent
+	delivered := m.waitForSentMessage(message)
 
 	// Save to database
 	profile, _ := m.db.GetProfileByURL(profileURL)
@@ -121,129 +192,184 @@ func (m *Messaging) SendMessage(profileURL string, message string) error {
 		profileID = profile.ID
 	}
 
-	msg := &database.Message{
-		ProfileID:  profileID,
-		ProfileURL: profileURL,
-		Content:    message,
+	msg.ProfileID = profileID
+	msg.Status = database.MessageSent
+	if !delivered {
+		msg.Status = database.MessageFailed
+		msg.Error = ErrNotDelivered.Error()
 	}
 
 	if err := m.db.AddMessage(msg); err != nil {
-		logger.Warn("Failed to save message", map[string]interface{}{"error": err.Error()})
+		log.Warn("Failed to save message", "error", err)
+	}
+
+	if !delivered {
+		return ErrNotDelivered
 	}
 
 	// Update daily stats
 	if err := m.db.IncrementDailyMessages(time.Now()); err != nil {
-		logger.Warn("Failed to increment daily messages", map[string]interface{}{"error": err.Error()})
+		log.Warn("Failed to increment daily messages", "error", err)
 	}
 
 	// Apply cooldown
 	m.stealth.MessageCooldown()
 
-	logger.Info("Message sent", map[string]interface{}{"profile_url": profileURL})
+	log.Info("Message sent", "profile_url", profileURL)
 	return nil
 }
 
-// findMessageButton finds the message button on the profile page
-func (m *Messaging) findMessageButton() (*rod.Element, error) {
-	selectors := []string{
-		"button[aria-label*='Message']",
-		"button:has-text('Message')",
-		"a[href*='/messaging/']",
-		"button.pvs-profile-actions__action",
+// dailyLimitReached reports whether messaging.daily_limit messages were
+// sent today. A failed check is logged and does not block sending.
+func (m *Messaging) dailyLimitReached() bool {
+	limit := m.config.Messaging.DailyLimit
+	if limit <= 0 {
+		return false
 	}
 
-	for _, selector := range selectors {
-		if !m.page.MustHas(selector) {
-			continue
+	stats, err := m.db.GetDailyStats(time.Now())
+	if err != nil {
+		log.Warn("Failed to check daily message limit", "error", err)
+		return false
+	}
+	return stats.MessagesSent >= limit
+}
+
+// failureArtifacts captures the page after a failed send. Sends skipped on
+// purpose are not failures and capture nothing.
+func (m *Messaging) failureArtifacts(err error) slog.Attr {
+	if errors.Is(err, ErrAlreadySent) || errors.Is(err, ErrReplied) || errors.Is(err, ErrDailyLimit) {
+		return slog.Attr{}
+	}
+	return artifacts.Capture(m.page, "message", err)
+}
+
+// send wraps SendMessage with retry bookkeeping
+func (m *Messaging) send(profileURL, message string) error {
+	return m.track(profileURL, message, m.SendMessage(profileURL, message))
+}
+
+// track records the result of a send attempt with the retry tracker. Sends
+// that were skipped on purpose are not counted as failures.
+func (m *Messaging) track(profileURL, message string, err error) error {
+	switch {
+	case err == nil:
+		m.retries.Success(profileURL)
+	case !errors.Is(err, ErrAlreadySent) && !errors.Is(err, ErrReplied) && !errors.Is(err, ErrDailyLimit):
+		m.retries.Failure(profileURL, message, err)
+	}
+	return err
+}
+
+// waitForSentMessage polls the open thread until our latest message matches
+// the text that was sent, giving up after verifyTimeout
+func (m *Messaging) waitForSentMessage(message string) bool {
+	want := normalizeText(message)
+	deadline := time.Now().Add(verifyTimeout)
+
+	for {
+		bodies, err := selectors.FindAll(m.page, "thread_sent_body")
+		if err == nil && len(bodies) > 0 {
+			if text, err := bodies[len(bodies)-1].Text(); err == nil && strings.Contains(normalizeText(text), want) {
+				return true
+			}
 		}
 
-		button, err := m.page.Element(selector)
-		if err != nil {
-			continue
+		if time.Now().After(deadline) {
+			log.Warn("Sent message not found in thread",
+				"timeout", verifyTimeout.String(),
+			)
+			return false
 		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
 
-		text := strings.ToLower(button.MustText())
-		if strings.Contains(text, "message") {
-			return button, nil
+// normalizeText collapses whitespace so rendered text compares equal to what
+// was typed
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// threadHasReply reports whether the open conversation contains a message
+// sent by the other participant
+func (m *Messaging) threadHasReply() bool {
+	return selectors.Has(m.page, "thread_reply")
+}
+
+// findMessageButton finds the message button on the profile page
+func (m *Messaging) findMessageButton() (*rod.Element, error) {
+	button, err := selectors.FindFunc(m.page, "message_button", func(button *rod.Element) bool {
+		text, _ := button.Text()
+		if strings.Contains(strings.ToLower(text), "message") {
+			return true
 		}
 
 		// Check href for messaging link
 		href, _ := button.Attribute("href")
-		if href != nil && strings.Contains(*href, "messaging") {
-			return button, nil
-		}
+		return href != nil && strings.Contains(*href, "messaging")
+	})
+	if err != nil {
+		return nil, fmt.Errorf("message button not found")
 	}
 
-	return nil, fmt.Errorf("message button not found")
+	return button, nil
 }
 
 // findMessageInput finds the message input field
 func (m *Messaging) findMessageInput() (*rod.Element, error) {
-	selectors := []string{
-		"div[contenteditable='true'][role='textbox']",
-		"textarea[placeholder*='message']",
-		"div[data-placeholder*='message']",
-		"div.msg-form__contenteditable",
-		"div[aria-label*='message']",
-	}
-
-	for _, selector := range selectors {
-		if !m.page.MustHas(selector) {
-			continue
-		}
-
-		input, err := m.page.Element(selector)
-		if err != nil {
-			continue
-		}
-
-		return input, nil
+	input, err := selectors.Find(m.page, "message_input")
+	if err != nil {
+		return nil, fmt.Errorf("message input not found")
 	}
 
-	return nil, fmt.Errorf("message input not found")
+	return input, nil
 }
 
 // findSendButton finds the send button
 func (m *Messaging) findSendButton() (*rod.Element, error) {
-	selectors := []string{
-		"button[aria-label*='Send']",
-		"button:has-text('Send')",
-		"button.msg-form__send-button",
-		"button[type='submit']",
-	}
-
-	for _, selector := range selectors {
-		if !m.page.MustHas(selector) {
-			continue
-		}
-
-		button, err := m.page.Element(selector)
-		if err != nil {
-			continue
-		}
-
-		text := strings.ToLower(button.MustText())
-		if strings.Contains(text, "send") {
-			return button, nil
+	button, err := selectors.FindFunc(m.page, "message_send_button", func(button *rod.Element) bool {
+		text, _ := button.Text()
+		if strings.Contains(strings.ToLower(text), "send") {
+			return true
 		}
 
 		// Check if it's a submit button in message form
 		disabled, _ := button.Attribute("disabled")
-		if disabled == nil || *disabled != "true" {
-			return button, nil
-		}
+		return disabled == nil || *disabled != "true"
+	})
+	if err != nil {
+		return nil, fmt.Errorf("send button not found")
 	}
 
-	return nil, fmt.Errorf("send button not found")
+	return button, nil
 }
 
-// SendFollowUpMessages sends follow-up messages to newly accepted connections
+// SendFollowUpMessages enrolls newly accepted connections in the follow-up
+// sequence and sends every step that is due
 func (m *Messaging) SendFollowUpMessages() error {
 	if !m.config.Messaging.Enabled {
 		return nil
 	}
 
-	logger.Info("Checking for newly accepted connections", nil)
+	// Record replies first so sequences of people who answered are stopped
+	if m.config.Messaging.InboxSync.Enabled {
+		if _, err := m.SyncInbox(); err != nil {
+			log.Warn("Inbox sync failed, continuing", "error", err, artifacts.Capture(m.page, "inbox", err))
+		}
+	}
+
+	if err := m.detectAcceptedConnections(); err != nil {
+		return err
+	}
+
+	return m.runSequences()
+}
+
+// detectAcceptedConnections visits pending invitations and starts the
+// follow-up sequence for those that were accepted
+func (m *Messaging) detectAcceptedConnections() error {
+	log.Info("Checking for newly accepted connections")
 
 	// Get pending connections
 	pendingConnections, err := m.db.GetPendingConnections()
@@ -251,112 +377,308 @@ func (m *Messaging) SendFollowUpMessages() error {
 		return fmt.Errorf("failed to get pending connections: %w", err)
 	}
 
+	steps := m.sequenceSteps()
+	followUpDelay := time.Duration(m.config.Messaging.FollowUpDelay) * time.Millisecond
+
 	// Check each connection to see if it was accepted
 	for _, conn := range pendingConnections {
 		// Check if enough time has passed since connection request
-		timeSinceRequest := time.Since(conn.SentAt)
-		if timeSinceRequest < m.config.Messaging.FollowUpDelay {
+		if time.Since(conn.SentAt) < followUpDelay {
 			continue
 		}
 
 		// Navigate to profile to check status
+		loadStart := time.Now()
 		if err := m.page.Navigate(conn.ProfileURL); err != nil {
-			logger.Warn("Failed to navigate to profile", map[string]interface{}{
-				"profile_url": conn.ProfileURL,
-				"error":       err.Error(),
-			})
+			log.Warn("Failed to navigate to profile",
+				"profile_url", conn.ProfileURL,
+				"error", err,
+				artifacts.Capture(m.page, "profile", err),
+			)
 			continue
 		}
 
 		m.page.MustWaitLoad()
+		metrics.ObservePageLoad("profile", loadStart)
 		m.stealth.RandomDelay()
 
 		// Check if connection was accepted (message button should be available)
-		if m.page.MustHas("button[aria-label*='Message']") {
-			// Connection accepted, send follow-up message
-			message := m.getFollowUpMessage(conn.ProfileURL)
-
-			if err := m.SendMessage(conn.ProfileURL, message); err != nil {
-				logger.Warn("Failed to send follow-up message", map[string]interface{}{
-					"profile_url": conn.ProfileURL,
-					"error":       err.Error(),
-				})
-				continue
+		if !selectors.Has(m.page, "connected_message_button") {
+			continue
+		}
+
+		metrics.InvitationsAccepted.Inc()
+		if err := m.db.UpdateConnectionRequestStatus(conn.ProfileURL, "accepted"); err != nil {
+			log.Warn("Failed to update connection status", "error", err)
+		}
+
+		firstDueAt := time.Now().Add(time.Duration(steps[0].Delay) * time.Millisecond)
+		if err := m.db.StartSequence(conn.ProfileID, conn.ProfileURL, firstDueAt); err != nil {
+			log.Warn("Failed to start follow-up sequence",
+				"profile_url", conn.ProfileURL,
+				"error", err,
+			)
+			continue
+		}
+
+		log.Info("Connection accepted, follow-up sequence started",
+			"profile_url", conn.ProfileURL,
+		)
+	}
+
+	return nil
+}
+
+// runSequences sends the next step of every sequence that is due, stopping
+// sequences whose prospect replied or was suppressed
+func (m *Messaging) runSequences() error {
+	sequences, err := m.db.GetDueSequences()
+	if err != nil {
+		return fmt.Errorf("failed to get due sequences: %w", err)
+	}
+
+	steps := m.sequenceSteps()
+
+	for _, seq := range sequences {
+		if seq.NextStep >= len(steps) {
+			// The sequence was shortened in the config since this profile enrolled
+			if err := m.db.AdvanceSequence(seq.ProfileURL, seq.NextStep, nil); err != nil {
+				log.Warn("Failed to complete sequence", "error", err)
 			}
+			continue
+		}
 
-			// Update connection status
-			if err := m.db.UpdateConnectionRequestStatus(conn.ProfileURL, "accepted"); err != nil {
-				logger.Warn("Failed to update connection status", map[string]interface{}{"error": err.Error()})
+		suppressed, err := m.db.IsSuppressed(seq.ProfileURL)
+		if err != nil {
+			log.Warn("Failed to check suppression", "error", err)
+			continue
+		}
+		if suppressed {
+			m.stopSequence(seq.ProfileURL, database.SequenceSuppressed)
+			continue
+		}
+
+		replied, err := m.db.HasReplied(seq.ProfileURL)
+		if err != nil {
+			log.Warn("Failed to check for replies", "error", err)
+			continue
+		}
+		if replied {
+			m.stopSequence(seq.ProfileURL, database.SequenceReplied)
+			continue
+		}
+
+		// Skip profiles whose last send failed recently or was dead-lettered
+		if !m.retries.Ready(seq.ProfileURL) {
+			continue
+		}
+
+		step := steps[seq.NextStep]
+		compose := func() (string, int) { return m.getFollowUpMessage(step.Templates, seq.ProfileURL) }
+
+		var draft *database.PendingAction
+		var message string
+		var templateIndex int
+		if m.approval.Required(seq.ProfileURL) {
+			draft, _, err = m.approval.Draft(seq.ProfileURL, seq.NextStep+1, compose)
+			if err != nil {
+				log.Warn("Failed to queue message for approval",
+					"profile_url", seq.ProfileURL,
+					"error", err,
+				)
+				continue
+			}
+			if draft.Status == database.ApprovalRejected {
+				m.stopSequence(seq.ProfileURL, database.SequenceRejected)
+				continue
+			}
+			if draft.Status != database.ApprovalApproved {
+				continue
 			}
+			message, templateIndex = draft.Body, draft.TemplateIndex
+		} else {
+			message, templateIndex = compose()
+		}
+
+		err = m.track(seq.ProfileURL, message, m.deliver(&database.Message{
+			ProfileURL:    seq.ProfileURL,
+			Content:       message,
+			SequenceStep:  seq.NextStep + 1,
+			TemplateIndex: templateIndex,
+		}, true))
+		if errors.Is(err, ErrReplied) {
+			m.stopSequence(seq.ProfileURL, database.SequenceReplied)
+			continue
+		}
+		if errors.Is(err, ErrDailyLimit) {
+			// The sequence stays due and continues tomorrow
+			log.Warn("Daily message limit reached")
+			break
+		}
+		if err != nil {
+			log.Warn("Failed to send follow-up message",
+				"profile_url", seq.ProfileURL,
+				"sequence_step", seq.NextStep+1,
+				"error", err,
+				m.failureArtifacts(err),
+			)
+			continue
+		}
+
+		m.approval.Sent(draft)
+
+		var nextDueAt *time.Time
+		if next := seq.NextStep + 1; next < len(steps) {
+			due := time.Now().Add(time.Duration(steps[next].Delay) * time.Millisecond)
+			nextDueAt = &due
+		}
 
-			logger.Info("Follow-up message sent", map[string]interface{}{"profile_url": conn.ProfileURL})
+		if err := m.db.AdvanceSequence(seq.ProfileURL, seq.NextStep+1, nextDueAt); err != nil {
+			log.Warn("Failed to advance sequence",
+				"profile_url", seq.ProfileURL,
+				"error", err,
+			)
 		}
+
+		log.Info("Follow-up message sent",
+			"profile_url", seq.ProfileURL,
+			"sequence_step", seq.NextStep+1,
+		)
 	}
 
 	return nil
 }
 
-// getFollowUpMessage generates a follow-up message from templates
-func (m *Messaging) getFollowUpMessage(profileURL string) string {
-	templates := m.config.Messaging.MessageTemplates
-	if len(templates) == 0 {
-		return "Hi! Thanks for connecting. I'd love to learn more about your work."
+func (m *Messaging) stopSequence(profileURL, status string) {
+	if err := m.db.StopSequence(profileURL, status); err != nil {
+		log.Warn("Failed to stop sequence",
+			"profile_url", profileURL,
+			"error", err,
+		)
+		return
+	}
+
+	log.Info("Follow-up sequence stopped",
+		"profile_url", profileURL,
+		"reason", status,
+	)
+}
+
+// sequenceSteps returns the configured sequence, falling back to a single
+// step that uses MessageTemplates
+func (m *Messaging) sequenceSteps() []config.SequenceStepConfig {
+	steps := m.config.Messaging.Sequence
+	if len(steps) == 0 {
+		steps = []config.SequenceStepConfig{{}}
+	}
+	return steps
+}
+
+// getFollowUpMessage generates a follow-up message from templates, falling
+// back to MessageTemplates when the step has none of its own. It returns the
+// message and the index of the chosen template, or -1 for the built-in default.
+func (m *Messaging) getFollowUpMessage(stepTemplates []config.MessageTemplate, profileURL string) (string, int) {
+	if len(stepTemplates) == 0 {
+		stepTemplates = m.config.Messaging.MessageTemplates
+	}
+	if len(stepTemplates) == 0 {
+		return "Hi! Thanks for connecting. I'd love to learn more about your work.", -1
 	}
 
-	// Select random template
-	template := templates[0] // In a real implementation, you'd randomize this
+	// Select a template by weight and expand its spintax
+	index, template := m.picker.Choose(stepTemplates)
+	template = m.picker.Spin(template)
 
 	// Personalize template
 	profile, err := m.db.GetProfileByURL(profileURL)
 	if err == nil && profile != nil {
-		template = strings.ReplaceAll(template, "{name}", profile.Name)
-		template = strings.ReplaceAll(template, "{title}", profile.Title)
-		template = strings.ReplaceAll(template, "{company}", profile.Company)
-		template = strings.ReplaceAll(template, "{location}", profile.Location)
-		template = strings.ReplaceAll(template, "{industry}", "your industry") // Could be extracted from profile
+		template = templates.Fill(template, MessageVars(profile))
 	}
 
-	return template
+	return template, index
+}
+
+// MessageVars returns the placeholder values available to message templates
+func MessageVars(profile *database.Profile) map[string]string {
+	return map[string]string{
+		"name":     profile.Name,
+		"title":    profile.Title,
+		"company":  profile.Company,
+		"location": profile.Location,
+		"industry": "your industry", // Could be extracted from profile
+	}
 }
 
 // SendBulkMessages sends messages to multiple profiles
 func (m *Messaging) SendBulkMessages(profiles []string, messageTemplate string) error {
 	successCount := 0
 	for _, profileURL := range profiles {
+		profileURL = profileurl.Canonical(profileURL)
+		if !m.retries.Ready(profileURL) {
+			continue
+		}
+
+		if suppressed, _ := m.db.IsSuppressed(profileURL); suppressed {
+			log.Info("Skipping suppressed profile", "profile_url", profileURL)
+			continue
+		}
+
 		// Personalize message
 		message := m.personalizeMessage(messageTemplate, profileURL)
 
-		if err := m.SendMessage(profileURL, message); err != nil {
-			logger.Warn("Failed to send message", map[string]interface{}{
-				"profile_url": profileURL,
-				"error":       err.Error(),
-			})
+		var draft *database.PendingAction
+		if m.approval.Required(profileURL) {
+			if sent, _ := m.db.HasMessage(profileURL); sent {
+				continue
+			}
+
+			var err error
+			draft, _, err = m.approval.Draft(profileURL, 0, func() (string, int) { return message, -1 })
+			if err != nil {
+				log.Warn("Failed to queue message for approval",
+					"profile_url", profileURL,
+					"error", err,
+				)
+				continue
+			}
+			if draft.Status != database.ApprovalApproved {
+				continue
+			}
+			message = draft.Body
+		}
+
+		if err := m.send(profileURL, message); errors.Is(err, ErrDailyLimit) {
+			log.Warn("Daily message limit reached")
+			break
+		} else if err != nil {
+			log.Warn("Failed to send message",
+				"profile_url", profileURL,
+				"error", err,
+				m.failureArtifacts(err),
+			)
 			continue
 		}
 
+		m.approval.Sent(draft)
 		successCount++
 	}
 
-	logger.Info("Bulk messages completed", map[string]interface{}{
-		"total":   len(profiles),
-		"success": successCount,
-	})
+	log.Info("Bulk messages completed",
+		"total", len(profiles),
+		"success", successCount,
+	)
 
 	return nil
 }
 
 // personalizeMessage personalizes a message template
 func (m *Messaging) personalizeMessage(template, profileURL string) string {
-	message := template
+	message := m.picker.Spin(template)
 
 	// Get profile from database
 	profile, err := m.db.GetProfileByURL(profileURL)
 	if err == nil && profile != nil {
-		message = strings.ReplaceAll(message, "{name}", profile.Name)
-		message = strings.ReplaceAll(message, "{title}", profile.Title)
-		message = strings.ReplaceAll(message, "{company}", profile.Company)
-		message = strings.ReplaceAll(message, "{location}", profile.Location)
-		message = strings.ReplaceAll(message, "{industry}", "your industry")
+		message = templates.Fill(message, MessageVars(profile))
 	}
 
 	return message
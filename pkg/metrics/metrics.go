@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"linkedin-automation/pkg/logger"
+)
+
+var log = logger.Component("metrics")
+
+// Default is the registry served on /metrics
+var Default = NewRegistry()
+
+var (
+	// Page loads take a few seconds; anything past 30s is a stall
+	pageLoadBuckets = []float64{0.5, 1, 2, 5, 10, 20, 30, 60}
+	// Actions include the human-like delays around clicks and typing
+	actionBuckets = []float64{1, 2, 5, 10, 20, 30, 60, 120, 300}
+)
+
+var (
+	ProfilesFound = Default.Counter("linkedin_profiles_found_total",
+		"Profiles saved for the first time, by source.", "source")
+
+	InvitationsSent = Default.Counter("linkedin_invitations_sent_total",
+		"Connection requests sent.")
+	InvitationsAccepted = Default.Counter("linkedin_invitations_accepted_total",
+		"Sent connection requests found to be accepted.")
+	InvitationsFailed = Default.Counter("linkedin_invitations_failed_total",
+		"Connection requests that failed.")
+
+	MessagesSent = Default.Counter("linkedin_messages_sent_total",
+		"Messages confirmed as delivered.")
+	MessagesFailed = Default.Counter("linkedin_messages_failed_total",
+		"Messages that could not be sent or were not confirmed.")
+
+	PageLoadSeconds = Default.Histogram("linkedin_page_load_duration_seconds",
+		"Time to navigate to a page and wait for it to load.", pageLoadBuckets, "page")
+	ActionSeconds = Default.Histogram("linkedin_action_duration_seconds",
+		"Time taken by a connection request or message, including pauses.", actionBuckets, "action")
+
+	DailyQuotaRemaining = Default.Gauge("linkedin_daily_quota_remaining",
+		"Actions that may still be taken today under the daily limit; +Inf without a limit.", "action")
+	QueueDepth = Default.Gauge("linkedin_queue_depth",
+		"Items waiting in each work queue.", "queue")
+
+	LastActivity = Default.Gauge("linkedin_last_activity_timestamp_seconds",
+		"Unix time of the last page load or action; alert when it stops moving.")
+)
+
+// Init sets the campaign label and starts the label-free counters at zero,
+// so rate() and increase() work before the first event
+func Init(campaign string) {
+	Default.SetCampaign(campaign)
+	for _, c := range []*Counter{InvitationsSent, InvitationsAccepted, InvitationsFailed, MessagesSent, MessagesFailed} {
+		c.Add(0)
+	}
+}
+
+// ObservePageLoad records how long a page took to load since start
+func ObservePageLoad(page string, start time.Time) {
+	PageLoadSeconds.Observe(time.Since(start).Seconds(), page)
+	LastActivity.Set(float64(time.Now().Unix()))
+}
+
+// ObserveAction records how long an action took since start
+func ObserveAction(action string, start time.Time) {
+	ActionSeconds.Observe(time.Since(start).Seconds(), action)
+	LastActivity.Set(float64(time.Now().Unix()))
+}
+
+// Handler serves the default registry in the Prometheus text format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := Default.Write(w); err != nil {
+			log.Debug("Failed to write metrics", "error", err)
+		}
+	})
+}
+
+// Serve starts an HTTP server exposing /metrics on addr. The listener is
+// opened before returning, so an address in use is reported to the caller.
+func Serve(addr string) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Warn("Metrics server stopped", "error", err)
+		}
+	}()
+
+	log.Info("Serving metrics", "address", ln.Addr().String())
+	return srv, nil
+}
@@ -0,0 +1,254 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CampaignLabel is the label every series carries
+const CampaignLabel = "campaign"
+
+// Metric types as written in the exposition format
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// Registry holds metric families and writes them in the Prometheus text
+// exposition format
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+	collect  []func()
+	campaign string
+}
+
+// family is one metric name with its series, keyed by label values
+type family struct {
+	name    string
+	help    string
+	typ     string
+	labels  []string // without the campaign label
+	buckets []float64
+	reg     *Registry
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	values []string // campaign first
+	value  float64
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// Counter only goes up
+type Counter struct{ f *family }
+
+// Gauge can be set to any value
+type Gauge struct{ f *family }
+
+// Histogram counts observations in buckets
+type Histogram struct{ f *family }
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{campaign: "default"}
+}
+
+// SetCampaign sets the campaign label of every series recorded from now on
+func (r *Registry) SetCampaign(campaign string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.campaign = campaign
+}
+
+// OnCollect registers a function that runs before every scrape, for gauges
+// that are read from elsewhere, such as the database
+func (r *Registry) OnCollect(f func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collect = append(r.collect, f)
+}
+
+// Counter registers a counter with the given labels besides campaign
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return &Counter{r.register(name, help, typeCounter, labels, nil)}
+}
+
+// Gauge registers a gauge
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{r.register(name, help, typeGauge, labels, nil)}
+}
+
+// Histogram registers a histogram with upper bucket bounds in ascending order
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return &Histogram{r.register(name, help, typeHistogram, labels, buckets)}
+}
+
+func (r *Registry) register(name, help, typ string, labels []string, buckets []float64) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f := &family{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+		reg:     r,
+	}
+	r.families = append(r.families, f)
+	return f
+}
+
+// Inc adds one to the series with the given label values
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Add adds v, which must not be negative
+func (c *Counter) Add(v float64, labels ...string) {
+	if v < 0 {
+		return
+	}
+	c.f.update(labels, func(s *series) { s.value += v })
+}
+
+// Set sets the series with the given label values to v
+func (g *Gauge) Set(v float64, labels ...string) {
+	g.f.update(labels, func(s *series) { s.value = v })
+}
+
+// Observe records one value
+func (h *Histogram) Observe(v float64, labels ...string) {
+	h.f.update(labels, func(s *series) {
+		if s.counts == nil {
+			s.counts = make([]uint64, len(h.f.buckets))
+		}
+		for i, bound := range h.f.buckets {
+			if v <= bound {
+				s.counts[i]++
+				break
+			}
+		}
+		s.sum += v
+		s.count++
+	})
+}
+
+func (f *family) update(labels []string, apply func(*series)) {
+	if len(labels) != len(f.labels) {
+		panic(fmt.Sprintf("metric %s takes %d label values, got %d", f.name, len(f.labels), len(labels)))
+	}
+
+	f.reg.mu.Lock()
+	campaign := f.reg.campaign
+	f.reg.mu.Unlock()
+
+	values := append([]string{campaign}, labels...)
+	key := strings.Join(values, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: values}
+		f.series[key] = s
+	}
+	apply(s)
+}
+
+// Write runs the collect functions and writes every family
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	collect := append([]func(){}, r.collect...)
+	families := append([]*family{}, r.families...)
+	r.mu.Unlock()
+
+	for _, f := range collect {
+		f()
+	}
+
+	var b strings.Builder
+	for _, f := range families {
+		f.write(&b)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (f *family) write(b *strings.Builder) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fmt.Fprintf(b, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(b, "# TYPE %s %s\n", f.name, f.typ)
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	names := append([]string{CampaignLabel}, f.labels...)
+	for _, key := range keys {
+		s := f.series[key]
+		labels := labelPairs(names, s.values)
+
+		if f.typ != typeHistogram {
+			fmt.Fprintf(b, "%s{%s} %s\n", f.name, labels, formatValue(s.value))
+			continue
+		}
+
+		var cumulative uint64
+		for i, bound := range f.buckets {
+			if s.counts != nil {
+				cumulative += s.counts[i]
+			}
+			fmt.Fprintf(b, "%s_bucket{%s,le=\"%s\"} %d\n", f.name, labels, formatValue(bound), cumulative)
+		}
+		fmt.Fprintf(b, "%s_bucket{%s,le=\"+Inf\"} %d\n", f.name, labels, s.count)
+		fmt.Fprintf(b, "%s_sum{%s} %s\n", f.name, labels, formatValue(s.sum))
+		fmt.Fprintf(b, "%s_count{%s} %d\n", f.name, labels, s.count)
+	}
+}
+
+func labelPairs(names, values []string) string {
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabel(values[i]) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
@@ -0,0 +1,91 @@
+package profileurl
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Host is the host every canonical profile URL uses
+const Host = "www.linkedin.com"
+
+// memberURNRe matches the member id inside URNs such as
+// "urn:li:fs_miniProfile:ACoAAB..." or "urn:li:fsd_profile:ACoAAB..."
+var memberURNRe = regexp.MustCompile(`urn:li:(?:fs_miniProfile|fsd_profile|member|fs_profile):([A-Za-z0-9_-]+)`)
+
+// Canonical returns the single form a LinkedIn profile URL is stored under,
+// "https://www.linkedin.com/in/<vanity>". It accepts relative hrefs,
+// links without a scheme, country subdomains, query strings and fragments,
+// trailing slashes, locale or section subpaths such as "/in/jane/fr" or
+// "/in/jane/details/experience", percent-encoded vanity names and bare
+// miniProfile URNs. Vanity names are case-insensitive on LinkedIn and are
+// lowercased; member ids ("ACoAA...") are case-sensitive and kept as they are.
+//
+// URLs that do not point at a profile are returned trimmed, without query
+// string, fragment or trailing slash.
+func Canonical(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+
+	if unescaped, err := url.QueryUnescape(raw); err == nil && strings.HasPrefix(unescaped, "urn:li:") {
+		if m := memberURNRe.FindStringSubmatch(unescaped); m != nil {
+			return build(m[1])
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(raw, "//"):
+		raw = "https:" + raw
+	case strings.HasPrefix(raw, "/"):
+		raw = "https://" + Host + raw
+	case strings.HasPrefix(raw, "in/"):
+		raw = "https://" + Host + "/" + raw
+	case !strings.Contains(raw, "://"):
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return strings.TrimSuffix(stripQuery(raw), "/")
+	}
+
+	segments := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+	for i, segment := range segments {
+		if segment == "in" && i+1 < len(segments) && segments[i+1] != "" {
+			if vanity, err := url.PathUnescape(segments[i+1]); err == nil {
+				return build(vanity)
+			}
+			return build(segments[i+1])
+		}
+	}
+
+	// Not a profile; only drop the parts that never identify a page
+	u.RawQuery = ""
+	u.Fragment = ""
+	u.Host = strings.ToLower(u.Host)
+	return strings.TrimSuffix(u.String(), "/")
+}
+
+// build assembles the canonical URL for a vanity name or member id
+func build(vanity string) string {
+	vanity = strings.TrimSpace(vanity)
+	if !isMemberID(vanity) {
+		vanity = strings.ToLower(vanity)
+	}
+	return "https://" + Host + "/in/" + url.PathEscape(vanity)
+}
+
+// isMemberID reports whether s is an opaque member id rather than a vanity
+// name. LinkedIn ids all start with "ACo" and are case-sensitive.
+func isMemberID(s string) bool {
+	return strings.HasPrefix(s, "ACo") && len(s) > 20
+}
+
+func stripQuery(raw string) string {
+	if i := strings.IndexAny(raw, "?#"); i >= 0 {
+		return raw[:i]
+	}
+	return raw
+}
@@ -0,0 +1,269 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenPhrase
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int // 1-based character position, for error messages
+}
+
+// SyntaxError describes where a query string could not be parsed
+type SyntaxError struct {
+	Query string
+	Pos   int // 1-based character position
+	Msg   string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d in %q", e.Msg, e.Pos, e.Query)
+}
+
+// Parse reads a boolean keyword string such as
+//
+//	golang AND (kubernetes OR k8s) NOT "project manager"
+//
+// Operators must be upper case, as LinkedIn only honours AND, OR and NOT
+// that way; words next to each other are joined with AND. Typographic quotes
+// pasted from documents are treated as plain quotes. An empty string parses
+// to a nil node.
+func Parse(input string) (*Node, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := &parser{input: input, tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t != nil {
+		// parseOr only stops early at a closing parenthesis
+		return nil, p.errorAt(t.pos, "unbalanced parenthesis: ')' has no matching '('")
+	}
+
+	if err := node.Validate(); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// Normalize parses input and renders it back, so that a valid query is
+// stored in one consistent form. Invalid queries return the parse error.
+func Normalize(input string) (string, error) {
+	node, err := Parse(input)
+	if err != nil {
+		return "", err
+	}
+	return node.String(), nil
+}
+
+func tokenize(input string) ([]token, error) {
+	runes := []rune(strings.NewReplacer("“", `"`, "”", `"`).Replace(input))
+
+	var tokens []token
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			i++
+
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenOpen, value: "(", pos: i + 1})
+			i++
+
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenClose, value: ")", pos: i + 1})
+			i++
+
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, &SyntaxError{Query: input, Pos: i + 1, Msg: "unbalanced quote: phrase is never closed"}
+			}
+			phrase := strings.Join(strings.Fields(string(runes[i+1:end])), " ")
+			if phrase == "" {
+				return nil, &SyntaxError{Query: input, Pos: i + 1, Msg: "empty phrase"}
+			}
+			tokens = append(tokens, token{kind: tokenPhrase, value: phrase, pos: i + 1})
+			i = end + 1
+
+		default:
+			start := i
+			for i < len(runes) && !strings.ContainsRune(" \t\n\r()\"", runes[i]) {
+				i++
+			}
+			word := string(runes[start:i])
+			t := token{kind: tokenWord, value: word, pos: start + 1}
+			switch word {
+			case "AND":
+				t.kind = tokenAnd
+			case "OR":
+				t.kind = tokenOr
+			case "NOT":
+				t.kind = tokenNot
+			default:
+				if isOperator(strings.ToUpper(word)) {
+					return nil, &SyntaxError{Query: input, Pos: start + 1,
+						Msg: fmt.Sprintf("lower-case %q would be searched as a word; write %s", word, strings.ToUpper(word))}
+				}
+			}
+			tokens = append(tokens, t)
+		}
+	}
+
+	return tokens, nil
+}
+
+func isOperator(word string) bool {
+	return word == "AND" || word == "OR" || word == "NOT"
+}
+
+type parser struct {
+	input  string
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() *token {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+func (p *parser) next() *token {
+	t := p.peek()
+	if t != nil {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorAt(pos int, msg string) error {
+	return &SyntaxError{Query: p.input, Pos: pos, Msg: msg}
+}
+
+// parseOr reads operands joined by OR, which binds loosest
+func (p *parser) parseOr() (*Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []*Node{left}
+	for {
+		t := p.peek()
+		if t == nil || t.kind != tokenOr {
+			break
+		}
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, right)
+	}
+
+	return Or(nodes...), nil
+}
+
+// parseAnd reads operands joined by AND or simply placed next to each other
+func (p *parser) parseAnd() (*Node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := []*Node{left}
+	for {
+		t := p.peek()
+		if t == nil || t.kind == tokenOr || t.kind == tokenClose {
+			break
+		}
+		if t.kind == tokenAnd {
+			p.next()
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, right)
+	}
+
+	return And(nodes...), nil
+}
+
+func (p *parser) parseNot() (*Node, error) {
+	if t := p.peek(); t != nil && t.kind == tokenNot {
+		p.next()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return Not(operand), nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (*Node, error) {
+	prev := p.pos - 1
+	t := p.next()
+	if t == nil {
+		if prev >= 0 {
+			last := p.tokens[prev]
+			return nil, p.errorAt(last.pos, fmt.Sprintf("%s is missing its right-hand term", last.value))
+		}
+		return nil, p.errorAt(1, "empty query")
+	}
+
+	switch t.kind {
+	case tokenWord:
+		return Term(t.value), nil
+	case tokenPhrase:
+		return Phrase(t.value), nil
+	case tokenOpen:
+		if next := p.peek(); next != nil && next.kind == tokenClose {
+			return nil, p.errorAt(t.pos, "empty group")
+		}
+		// Nesting is limited by Validate, which also sees queries built in
+		// code or YAML
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing == nil || closing.kind != tokenClose {
+			return nil, p.errorAt(t.pos, "unbalanced parenthesis: '(' is never closed")
+		}
+		return node, nil
+	case tokenClose:
+		return nil, p.errorAt(t.pos, "unbalanced parenthesis: ')' has no matching '('")
+	default:
+		if prev >= 0 && p.tokens[prev].kind != tokenOpen {
+			return nil, p.errorAt(t.pos, fmt.Sprintf("%s follows another operator", t.value))
+		}
+		return nil, p.errorAt(t.pos, fmt.Sprintf("%s is missing its left-hand term", t.value))
+	}
+}
@@ -0,0 +1,337 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// MaxOperators caps the AND, OR and NOT operators in one query. LinkedIn
+	// silently drops parts of very long boolean strings instead of failing.
+	MaxOperators = 20
+
+	// MaxDepth caps how many AND and OR levels may be nested, counting the
+	// outermost; Parse and Validate share this one rule
+	MaxDepth = 4
+)
+
+// Kind identifies the type of a query node
+type Kind string
+
+const (
+	KindTerm   Kind = "term"   // a single word
+	KindPhrase Kind = "phrase" // an exact phrase, rendered in quotes
+	KindAnd    Kind = "and"    // every child must match
+	KindOr     Kind = "or"     // any child may match
+	KindNot    Kind = "not"    // the only child must not match
+)
+
+// Node is one element of a boolean keyword query. Terms and phrases carry
+// Value; AND and OR carry two or more Children, NOT exactly one.
+type Node struct {
+	Kind     Kind
+	Value    string
+	Children []*Node
+}
+
+// Term returns a single-word node
+func Term(word string) *Node {
+	return &Node{Kind: KindTerm, Value: word}
+}
+
+// Phrase returns an exact-phrase node
+func Phrase(text string) *Node {
+	return &Node{Kind: KindPhrase, Value: text}
+}
+
+// Literal returns a term for a single word and a phrase for several, so
+// user input can be searched for exactly as given
+func Literal(text string) *Node {
+	words := strings.Fields(text)
+	switch len(words) {
+	case 0:
+		return nil
+	case 1:
+		return Term(words[0])
+	default:
+		return Phrase(strings.Join(words, " "))
+	}
+}
+
+// And combines nodes so that all of them must match. Nil nodes are skipped
+// and a single node is returned as is.
+func And(nodes ...*Node) *Node {
+	return group(KindAnd, nodes)
+}
+
+// Or combines nodes so that any of them may match
+func Or(nodes ...*Node) *Node {
+	return group(KindOr, nodes)
+}
+
+// Not negates a node
+func Not(node *Node) *Node {
+	if node == nil {
+		return nil
+	}
+	return &Node{Kind: KindNot, Children: []*Node{node}}
+}
+
+func group(kind Kind, nodes []*Node) *Node {
+	var children []*Node
+	for _, n := range nodes {
+		if n == nil {
+			continue
+		}
+		// Flatten nested groups of the same kind, (a AND b) AND c
+		if n.Kind == kind {
+			children = append(children, n.Children...)
+			continue
+		}
+		children = append(children, n)
+	}
+
+	switch len(children) {
+	case 0:
+		return nil
+	case 1:
+		return children[0]
+	default:
+		return &Node{Kind: kind, Children: children}
+	}
+}
+
+// String renders the query in LinkedIn's boolean syntax. OR groups inside
+// AND, AND groups inside OR and compound operands of NOT are parenthesised so
+// the result does not depend on LinkedIn's operator precedence.
+func (n *Node) String() string {
+	if n == nil {
+		return ""
+	}
+
+	switch n.Kind {
+	case KindTerm:
+		return n.Value
+	case KindPhrase:
+		return `"` + n.Value + `"`
+	case KindNot:
+		return "NOT " + n.Children[0].operand(KindNot)
+	case KindAnd, KindOr:
+		parts := make([]string, len(n.Children))
+		for i, child := range n.Children {
+			parts[i] = child.operand(n.Kind)
+		}
+		return strings.Join(parts, " "+strings.ToUpper(string(n.Kind))+" ")
+	default:
+		return ""
+	}
+}
+
+// operand renders n as an operand of parent, adding parentheses when needed
+func (n *Node) operand(parent Kind) string {
+	if n.Kind == KindAnd || n.Kind == KindOr {
+		if parent != n.Kind {
+			return "(" + n.String() + ")"
+		}
+	}
+	return n.String()
+}
+
+// Validate checks a query built in code or loaded from YAML against the same
+// rules Parse applies
+func (n *Node) Validate() error {
+	if n == nil {
+		return nil
+	}
+
+	operators, err := n.validate(0)
+	if err != nil {
+		return err
+	}
+	if operators > MaxOperators {
+		return fmt.Errorf("query uses %d operators, the limit is %d", operators, MaxOperators)
+	}
+	if n.onlyNegative() {
+		return fmt.Errorf("query %q only excludes terms; add at least one term to search for", n.String())
+	}
+	return nil
+}
+
+// validate checks n and its children, returning the number of operators
+func (n *Node) validate(depth int) (int, error) {
+	switch n.Kind {
+	case KindTerm:
+		if strings.TrimSpace(n.Value) == "" {
+			return 0, fmt.Errorf("empty term")
+		}
+		if strings.ContainsAny(n.Value, "\"() \t\n") {
+			return 0, fmt.Errorf("term %q contains spaces, quotes or parentheses; use a phrase or a group", n.Value)
+		}
+		if isOperator(strings.ToUpper(n.Value)) {
+			return 0, fmt.Errorf("term %q would be read as an operator", n.Value)
+		}
+		return 0, nil
+
+	case KindPhrase:
+		if strings.TrimSpace(n.Value) == "" {
+			return 0, fmt.Errorf("empty phrase")
+		}
+		if strings.Contains(n.Value, `"`) {
+			return 0, fmt.Errorf("phrase %q contains a quote", n.Value)
+		}
+		return 0, nil
+
+	case KindNot:
+		if len(n.Children) != 1 || n.Children[0] == nil {
+			return 0, fmt.Errorf("NOT needs exactly one operand")
+		}
+		ops, err := n.Children[0].validate(depth)
+		return ops + 1, err
+
+	case KindAnd, KindOr:
+		if len(n.Children) < 2 {
+			return 0, fmt.Errorf("%s needs at least two operands", strings.ToUpper(string(n.Kind)))
+		}
+		if depth >= MaxDepth {
+			return 0, fmt.Errorf("groups are nested more than %d deep", MaxDepth)
+		}
+		ops := len(n.Children) - 1
+		for _, child := range n.Children {
+			if child == nil {
+				return 0, fmt.Errorf("%s has an empty operand", strings.ToUpper(string(n.Kind)))
+			}
+			childOps, err := child.validate(depth + 1)
+			if err != nil {
+				return 0, err
+			}
+			ops += childOps
+		}
+		return ops, nil
+
+	default:
+		return 0, fmt.Errorf("unknown query node %q", n.Kind)
+	}
+}
+
+// onlyNegative reports whether nothing in the query is searched for, as in
+// "NOT recruiter" or "NOT a AND NOT b"
+func (n *Node) onlyNegative() bool {
+	switch n.Kind {
+	case KindNot:
+		return true
+	case KindAnd, KindOr:
+		for _, child := range n.Children {
+			if !child.onlyNegative() {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// UnmarshalYAML reads a query from YAML. A string is parsed as a boolean
+// expression, a list is an implicit AND, and a mapping with a single "and",
+// "or", "not" or "phrase" key builds that node:
+//
+//	keywords:
+//	  and:
+//	    - golang
+//	    - or: [kubernetes, k8s]
+//	    - phrase: site reliability
+//	    - not: recruiter
+func (n *Node) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := fromYAML(value)
+	if err != nil {
+		return err
+	}
+	if parsed == nil {
+		*n = Node{}
+		return nil
+	}
+	if err := parsed.Validate(); err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	*n = *parsed
+	return nil
+}
+
+// IsZero reports whether the node is empty, which is what a blank YAML
+// value unmarshals to
+func (n *Node) IsZero() bool {
+	return n == nil || n.Kind == ""
+}
+
+func fromYAML(value *yaml.Node) (*Node, error) {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		node, err := Parse(value.Value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", value.Line, err)
+		}
+		return node, nil
+
+	case yaml.SequenceNode:
+		children, err := yamlList(value)
+		if err != nil {
+			return nil, err
+		}
+		return And(children...), nil
+
+	case yaml.MappingNode:
+		if len(value.Content) != 2 {
+			return nil, fmt.Errorf("line %d: a query mapping needs exactly one of and, or, not or phrase", value.Line)
+		}
+		key, body := value.Content[0].Value, value.Content[1]
+
+		switch strings.ToLower(key) {
+		case "and", "or":
+			children, err := yamlList(body)
+			if err != nil {
+				return nil, err
+			}
+			if strings.ToLower(key) == "and" {
+				return And(children...), nil
+			}
+			return Or(children...), nil
+		case "not":
+			child, err := fromYAML(body)
+			if err != nil {
+				return nil, err
+			}
+			if child == nil {
+				return nil, fmt.Errorf("line %d: not needs an operand", body.Line)
+			}
+			return Not(child), nil
+		case "phrase":
+			if body.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: phrase must be a string", body.Line)
+			}
+			return Phrase(strings.TrimSpace(body.Value)), nil
+		default:
+			return nil, fmt.Errorf("line %d: unknown query operator %q", value.Line, key)
+		}
+
+	default:
+		return nil, fmt.Errorf("line %d: unsupported query value", value.Line)
+	}
+}
+
+func yamlList(value *yaml.Node) ([]*Node, error) {
+	if value.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("line %d: expected a list of queries", value.Line)
+	}
+
+	var children []*Node
+	for _, item := range value.Content {
+		child, err := fromYAML(item)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	return children, nil
+}
@@ -0,0 +1,180 @@
+package retry
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+
+	"linkedin-automation/pkg/config"
+	"linkedin-automation/pkg/database"
+	"linkedin-automation/pkg/logger"
+)
+
+var log = logger.Component("retry")
+
+// Action types tracked for retries
+const (
+	ActionConnect = "connect"
+	ActionMessage = "message"
+)
+
+// Default policy values used when the config leaves them unset
+const (
+	defaultMaxAttempts = 5
+	defaultBaseDelay   = 15 * time.Minute
+	defaultMaxDelay    = 24 * time.Hour
+)
+
+// Policy describes exponential backoff with a cap on attempts
+type Policy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// permanentError marks an error that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that the tracker dead-letters the action immediately
+// instead of backing off
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// NewPolicy builds a policy from the retry config, filling in defaults
+func NewPolicy(cfg config.RetryConfig) Policy {
+	p := Policy{
+		MaxAttempts: cfg.MaxAttempts,
+		BaseDelay:   time.Duration(cfg.BaseDelay) * time.Millisecond,
+		MaxDelay:    time.Duration(cfg.MaxDelay) * time.Millisecond,
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaultMaxAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = defaultBaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = defaultMaxDelay
+	}
+	return p
+}
+
+// Backoff returns the delay before the next attempt after the given number of
+// failed attempts, doubling each time with up to 20% jitter
+func (p Policy) Backoff(attempts int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempts && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(delay)/5 + 1))
+	return delay - jitter
+}
+
+// Tracker records failures and successes of one action type
+type Tracker struct {
+	db     *database.DB
+	policy Policy
+	action string
+}
+
+// NewTracker creates a tracker for the given action type
+func NewTracker(cfg *config.Config, db *database.DB, action string) *Tracker {
+	return &Tracker{
+		db:     db,
+		policy: NewPolicy(cfg.Retry),
+		action: action,
+	}
+}
+
+// Ready reports whether the action may be attempted on the profile now, that
+// is it is neither dead-lettered nor waiting out a backoff
+func (t *Tracker) Ready(profileURL string) bool {
+	blocked, err := t.db.IsActionBlocked(t.action, profileURL)
+	if err != nil {
+		log.Warn("Failed to check retry state",
+			"action", t.action,
+			"profile_url", profileURL,
+			"error", err,
+		)
+		return true
+	}
+	return !blocked
+}
+
+// Failure records a failed attempt and schedules the next one, moving the
+// action to the dead-letter state once the policy is exhausted
+func (t *Tracker) Failure(profileURL, payload string, cause error) {
+	r, err := t.db.GetActionRetry(t.action, profileURL)
+	if err != nil {
+		log.Warn("Failed to load retry state",
+			"action", t.action,
+			"profile_url", profileURL,
+			"error", err,
+		)
+		return
+	}
+	if r == nil {
+		r = &database.ActionRetry{Action: t.action, ProfileURL: profileURL}
+	}
+
+	r.Attempts++
+	r.Payload = payload
+	r.LastError = cause.Error()
+	r.State = database.RetryStateRetrying
+	r.NextAttemptAt = time.Now().Add(t.policy.Backoff(r.Attempts))
+
+	if r.Attempts >= t.policy.MaxAttempts || IsPermanent(cause) {
+		r.State = database.RetryStateDead
+	}
+
+	if err := t.db.SaveActionRetry(r); err != nil {
+		log.Warn("Failed to save retry state",
+			"action", t.action,
+			"profile_url", profileURL,
+			"error", err,
+		)
+		return
+	}
+
+	l := log.With(
+		"action", t.action,
+		"profile_url", profileURL,
+		"attempts", r.Attempts,
+		"error", r.LastError,
+	)
+	if r.State == database.RetryStateDead {
+		l.Warn("Action moved to dead-letter state")
+		return
+	}
+	l.Info("Action scheduled for retry", "next_attempt_at", r.NextAttemptAt.Format(time.RFC3339))
+}
+
+// Success clears any retry state after the action went through
+func (t *Tracker) Success(profileURL string) {
+	if err := t.db.DeleteActionRetry(t.action, profileURL); err != nil {
+		log.Warn("Failed to clear retry state",
+			"action", t.action,
+			"profile_url", profileURL,
+			"error", err,
+		)
+	}
+}
@@ -0,0 +1,301 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"linkedin-automation/pkg/artifacts"
+	"linkedin-automation/pkg/database"
+	"linkedin-automation/pkg/metrics"
+	"linkedin-automation/pkg/query"
+	"linkedin-automation/pkg/selectors"
+)
+
+var (
+	// companyIDRe matches a company entity URN and captures its numeric id
+	companyIDRe = regexp.MustCompile(`urn:li:(?:fsd_company|fs_normalized_company|fs_miniCompany|company):(\d+)`)
+
+	// companyTypeaheadURNRe matches the target of a company typeahead hit
+	companyTypeaheadURNRe = regexp.MustCompile(`^urn:li:(?:fs_miniCompany|company|fsd_company):(\d+)$`)
+
+	// companyNumberRe matches a numeric company id
+	companyNumberRe = regexp.MustCompile(`^\d+$`)
+)
+
+// SearchCompany resolves a company from its page URL, slug, id or name and
+// searches for its current employees, limited to any of titles when given.
+// Every profile the search finds is linked to the company, including ones
+// already in the database.
+func (s *Search) SearchCompany(target string, titles []string) (*database.Company, []Profile, error) {
+	company, err := s.ResolveCompany(target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var titleQuery []*query.Node
+	for _, title := range titles {
+		titleQuery = append(titleQuery, query.Literal(title))
+	}
+
+	params := SearchParams{
+		JobTitle:         query.Or(titleQuery...).String(),
+		CurrentCompanies: []string{company.LinkedInID},
+	}
+
+	profiles, err := s.SearchProfiles(params)
+	return company, profiles, err
+}
+
+// ResolveCompany finds the company a target refers to and stores it. A
+// company page URL, "company/<slug>" or a numeric id is opened directly;
+// anything else is treated as a name or slug, looked up among stored
+// companies and then through the site's company typeahead.
+func (s *Search) ResolveCompany(target string) (*database.Company, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return nil, fmt.Errorf("empty company")
+	}
+
+	ref := companyRef(target)
+	if ref == "" {
+		known, err := s.db.FindCompany(target)
+		if err != nil {
+			log.Warn("Failed to look up stored company",
+				"company", target,
+				"error", err,
+			)
+		}
+		if known != nil {
+			return known, nil
+		}
+
+		id, name, err := s.companyTypeahead(target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve company %q: %w", target, err)
+		}
+		log.Info("Company resolved through typeahead",
+			"company", target,
+			"match", name,
+			"id", id,
+		)
+		ref = id
+	}
+
+	company, err := s.readCompanyPage(ref)
+	if err != nil {
+		log.Warn("Failed to read company page",
+			"company", target,
+			"error", err,
+			artifacts.Capture(s.page, "company", err),
+		)
+		return nil, fmt.Errorf("failed to read company page for %q: %w", target, err)
+	}
+
+	if err := s.db.UpsertCompany(company); err != nil {
+		return nil, fmt.Errorf("failed to save company: %w", err)
+	}
+
+	log.Info("Company resolved",
+		"name", company.Name,
+		"linkedin_id", company.LinkedInID,
+		"url", company.URL,
+	)
+
+	return company, nil
+}
+
+// readCompanyPage opens linkedin.com/company/<ref> and reads the company's
+// id, slug and name. LinkedIn redirects numeric ids to the slug.
+func (s *Search) readCompanyPage(ref string) (*database.Company, error) {
+	loadStart := time.Now()
+	if err := s.page.Navigate(s.config.LinkedIn.BaseURL + "/company/" + url.PathEscape(ref) + "/"); err != nil {
+		return nil, fmt.Errorf("failed to navigate to company page: %w", err)
+	}
+	s.page.MustWaitLoad()
+	metrics.ObservePageLoad("company", loadStart)
+	s.stealth.RandomDelay()
+
+	company := &database.Company{Name: selectors.Text(s.page, "company_name")}
+
+	if info, err := s.page.Info(); err == nil {
+		if slug := companyRef(info.URL); slug != "" && !companyNumberRe.MatchString(slug) {
+			company.UniversalName = slug
+		}
+	}
+	if company.UniversalName == "" && !companyNumberRe.MatchString(ref) {
+		company.UniversalName = ref
+	}
+
+	id, err := s.companyPageID(company.UniversalName)
+	switch {
+	case companyNumberRe.MatchString(ref):
+		// The id was given or came from the typeahead; a page about
+		// another company means the search would target the wrong one
+		if err == nil && id != ref {
+			return nil, fmt.Errorf("company page shows id %s, expected %s", id, ref)
+		}
+		company.LinkedInID = ref
+	case err != nil:
+		return nil, fmt.Errorf("%w, is %q a company?", err, ref)
+	default:
+		company.LinkedInID = id
+	}
+
+	if company.UniversalName != "" {
+		company.URL = s.config.LinkedIn.BaseURL + "/company/" + company.UniversalName
+	} else {
+		company.URL = s.config.LinkedIn.BaseURL + "/company/" + company.LinkedInID
+	}
+
+	return company, nil
+}
+
+// companyPageID returns the id of the company the open page is about: the
+// entity URN on its top card, or else the embedded record whose
+// universalName is the page's slug. Other companies on the page, such as
+// affiliated or similar pages, are not mistaken for it.
+func (s *Search) companyPageID(universalName string) (string, error) {
+	if card, err := selectors.Find(s.page, "company_top_card"); err == nil {
+		if urn, err := card.Attribute("data-entity-urn"); err == nil && urn != nil {
+			if m := companyIDRe.FindStringSubmatch(*urn); m != nil {
+				return m[1], nil
+			}
+		}
+	}
+
+	if universalName != "" {
+		body, err := s.page.HTML()
+		if err != nil {
+			return "", fmt.Errorf("failed to read company page: %w", err)
+		}
+		if id := companyIDForName(html.UnescapeString(body), universalName); id != "" {
+			return id, nil
+		}
+	}
+
+	return "", fmt.Errorf("company id not found on page")
+}
+
+// companyIDForName finds the company record with the given universalName in
+// the JSON embedded in a page and returns its id
+func companyIDForName(page, universalName string) string {
+	name := `"universalName":"` + regexp.QuoteMeta(universalName) + `"`
+	urn := `"entityUrn":"urn:li:(?:fsd_company|fs_normalized_company|fs_miniCompany|company):(\d+)"`
+	for _, re := range []*regexp.Regexp{
+		regexp.MustCompile(`(?i)` + urn + `[^{}]*` + name),
+		regexp.MustCompile(`(?i)` + name + `[^{}]*` + urn),
+	} {
+		if m := re.FindStringSubmatch(page); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// companyTypeaheadJS queries the company typeahead from inside the
+// logged-in page, like typeaheadJS does for locations
+const companyTypeaheadJS = `async (keywords) => {
+	const csrf = (document.cookie.match(/JSESSIONID="?([^";]+)/) || [])[1] || '';
+	const url = '/voyager/api/typeahead/hitsV2?keywords=' + encodeURIComponent(keywords) +
+		'&origin=OTHER&q=type&type=COMPANY';
+	const res = await fetch(url, {
+		credentials: 'include',
+		headers: {'csrf-token': csrf, 'accept': 'application/json'},
+	});
+	if (!res.ok) {
+		throw new Error('typeahead returned HTTP ' + res.status);
+	}
+	return JSON.stringify(await res.json());
+}`
+
+// companyTypeahead returns the id and display name of the first company hit
+func (s *Search) companyTypeahead(name string) (string, string, error) {
+	res, err := s.page.Eval(companyTypeaheadJS, name)
+	if err != nil {
+		return "", "", err
+	}
+
+	var body struct {
+		Elements []struct {
+			TargetURN string `json:"targetUrn"`
+			Text      struct {
+				Text string `json:"text"`
+			} `json:"text"`
+		} `json:"elements"`
+	}
+	if err := json.Unmarshal([]byte(res.Value.Str()), &body); err != nil {
+		return "", "", fmt.Errorf("unexpected typeahead response: %w", err)
+	}
+
+	for _, el := range body.Elements {
+		if m := companyTypeaheadURNRe.FindStringSubmatch(el.TargetURN); m != nil {
+			return m[1], el.Text.Text, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("no matching company")
+}
+
+// companyRef extracts the slug or id from a company page URL or
+// "company/<slug>", and accepts a bare numeric id. Names yield "".
+func companyRef(target string) string {
+	if companyNumberRe.MatchString(target) {
+		return target
+	}
+
+	path := target
+	if u, err := url.Parse(target); err == nil && u.Host != "" {
+		path = u.Path
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		if segment == "company" && i+1 < len(segments) && segments[i+1] != "" {
+			slug, err := url.PathUnescape(segments[i+1])
+			if err != nil {
+				slug = segments[i+1]
+			}
+			return strings.ToLower(slug)
+		}
+	}
+	return ""
+}
+
+// companyFor returns the stored company a search is limited to when its
+// current-company facet names exactly one, so the profiles found can be
+// linked to it
+func (s *Search) companyFor(params SearchParams) *database.Company {
+	ids := facetIDs(params.CurrentCompanies)
+	if len(ids) != 1 {
+		return nil
+	}
+
+	company, err := s.db.GetCompanyByLinkedInID(ids[0])
+	if err != nil {
+		log.Debug("Failed to look up company",
+			"linkedin_id", ids[0],
+			"error", err,
+		)
+		return nil
+	}
+	return company
+}
+
+// linkCompany records that a profile found by a company search works there
+func (s *Search) linkCompany(profileURL string, company *database.Company) {
+	if company == nil {
+		return
+	}
+	if err := s.db.LinkProfileCompany(profileURL, company); err != nil {
+		log.Debug("Failed to link profile to company",
+			"url", profileURL,
+			"company", company.Name,
+			"error", err,
+		)
+	}
+}
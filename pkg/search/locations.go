@@ -0,0 +1,244 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"linkedin-automation/pkg/config"
+	"linkedin-automation/pkg/database"
+
+	"github.com/go-rod/rod"
+)
+
+// bundledLocations maps common location names to LinkedIn geo ids. Keys are
+// normalised with normalizeLocation.
+var bundledLocations = map[string]string{
+	// Countries
+	"united states":  "103644278",
+	"usa":            "103644278",
+	"us":             "103644278",
+	"united kingdom": "101165590",
+	"uk":             "101165590",
+	"canada":         "101174742",
+	"germany":        "101282230",
+	"france":         "105015875",
+	"india":          "102713980",
+	"australia":      "101452733",
+	"netherlands":    "102890719",
+	"spain":          "105646813",
+	"italy":          "103350119",
+	"ireland":        "104738515",
+	"sweden":         "105117694",
+	"switzerland":    "106693272",
+	"singapore":      "102454443",
+	"brazil":         "106057199",
+	"israel":         "101620260",
+	"japan":          "101355337",
+	"poland":         "105072130",
+	"mexico":         "103323778",
+
+	// Regions and states
+	"europe":         "100506914",
+	"california":     "102095887",
+	"new york state": "105080838",
+	"texas":          "102748797",
+
+	// Metropolitan areas
+	"san francisco bay area": "90000084",
+	"san francisco":          "90000084",
+	"bay area":               "90000084",
+	"sf":                     "90000084",
+	"new york city":          "90000070",
+	"new york":               "90000070",
+	"nyc":                    "90000070",
+	"greater seattle area":   "90000091",
+	"seattle":                "90000091",
+	"greater boston":         "90000007",
+	"boston":                 "90000007",
+	"greater los angeles":    "90000049",
+	"los angeles":            "90000049",
+	"greater chicago area":   "90000014",
+	"chicago":                "90000014",
+	"austin":                 "90000064",
+	"london":                 "90009496",
+	"greater london":         "90009496",
+	"toronto":                "90009551",
+	"bengaluru":              "105214831",
+	"bangalore":              "105214831",
+}
+
+var (
+	geoIDRe      = regexp.MustCompile(`^\d+$`)
+	geoURNRe     = regexp.MustCompile(`^urn:li:(?:fs_)?geo:(\d+)$`)
+	whitespaceRe = regexp.MustCompile(`\s+`)
+)
+
+// LocationResolver turns location names into LinkedIn geo URNs. It tries the
+// configured overrides, the bundled table, the database cache and finally
+// the site's location typeahead, caching what the typeahead returns.
+type LocationResolver struct {
+	overrides map[string]string
+	page      *rod.Page
+	db        *database.DB
+}
+
+// NewLocationResolver creates a resolver using the search.locations overrides
+func NewLocationResolver(cfg *config.Config, page *rod.Page, db *database.DB) *LocationResolver {
+	overrides := make(map[string]string)
+	for name, urn := range cfg.Search.Locations {
+		overrides[normalizeLocation(name)] = urn
+	}
+
+	return &LocationResolver{
+		overrides: overrides,
+		page:      page,
+		db:        db,
+	}
+}
+
+// Resolve returns the geo URN, such as "urn:li:geo:103644278", for a
+// location name. A URN or bare id is returned as is. Names of the form
+// "City, Region" fall back to the known city only when the typeahead cannot
+// resolve the full name, with a warning, since the city alone may be a
+// different place.
+func (r *LocationResolver) Resolve(location string) (string, error) {
+	if urn := toGeoURN(location); urn != "" {
+		return urn, nil
+	}
+
+	query := normalizeLocation(location)
+	if query == "" {
+		return "", fmt.Errorf("empty location")
+	}
+
+	if urn, ok := r.lookup(query); ok {
+		return urn, nil
+	}
+
+	urn, name, err := r.typeahead(location)
+	if err != nil {
+		if city, _, ok := strings.Cut(query, ","); ok {
+			city = strings.TrimSpace(city)
+			if urn, ok := r.lookup(city); ok {
+				log.Warn("Location could not be resolved, using its city instead",
+					"location", location,
+					"city", city,
+					"urn", urn,
+					"error", err,
+				)
+				return urn, nil
+			}
+		}
+		return "", fmt.Errorf("failed to resolve location %q: %w", location, err)
+	}
+
+	if err := r.db.CacheLocation(query, urn, name); err != nil {
+		log.Warn("Failed to cache location",
+			"location", location,
+			"error", err,
+		)
+	}
+
+	log.Info("Location resolved through typeahead",
+		"location", location,
+		"match", name,
+		"urn", urn,
+	)
+
+	return urn, nil
+}
+
+// lookup resolves a normalised name without the typeahead, through the
+// overrides, the bundled table and the database cache
+func (r *LocationResolver) lookup(query string) (string, bool) {
+	if urn := toGeoURN(r.overrides[query]); urn != "" {
+		return urn, true
+	}
+	if id, ok := bundledLocations[query]; ok {
+		return "urn:li:geo:" + id, true
+	}
+
+	urn, _, found, err := r.db.GetCachedLocation(query)
+	if err != nil {
+		log.Warn("Failed to read location cache",
+			"location", query,
+			"error", err,
+		)
+	}
+	return urn, found
+}
+
+// typeaheadJS queries the location typeahead from inside the logged-in page,
+// which supplies the session cookies and the CSRF token
+const typeaheadJS = `async (keywords) => {
+	const csrf = (document.cookie.match(/JSESSIONID="?([^";]+)/) || [])[1] || '';
+	const url = '/voyager/api/typeahead/hitsV2?keywords=' + encodeURIComponent(keywords) +
+		'&origin=OTHER&q=type&queryContext=List(geoVersion-%3E3,bingGeoSubTypeFilters-%3EMARKET_AREA%7CCOUNTRY_REGION%7CADMIN_DIVISION_1%7CCITY)&type=GEO';
+	const res = await fetch(url, {
+		credentials: 'include',
+		headers: {'csrf-token': csrf, 'accept': 'application/json'},
+	});
+	if (!res.ok) {
+		throw new Error('typeahead returned HTTP ' + res.status);
+	}
+	return JSON.stringify(await res.json());
+}`
+
+// typeahead returns the first geo hit for keywords along with its display name
+func (r *LocationResolver) typeahead(keywords string) (string, string, error) {
+	if r.page == nil {
+		return "", "", fmt.Errorf("no browser session for typeahead lookup")
+	}
+
+	res, err := r.page.Eval(typeaheadJS, keywords)
+	if err != nil {
+		return "", "", err
+	}
+
+	var body struct {
+		Elements []struct {
+			TargetURN string `json:"targetUrn"`
+			Text      struct {
+				Text string `json:"text"`
+			} `json:"text"`
+		} `json:"elements"`
+	}
+	if err := json.Unmarshal([]byte(res.Value.Str()), &body); err != nil {
+		return "", "", fmt.Errorf("unexpected typeahead response: %w", err)
+	}
+
+	for _, el := range body.Elements {
+		if urn := toGeoURN(el.TargetURN); urn != "" {
+			return urn, el.Text.Text, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("no matching location")
+}
+
+// toGeoURN converts "urn:li:geo:<id>", "urn:li:fs_geo:<id>" or a bare id to
+// "urn:li:geo:<id>". Anything else yields an empty string.
+func toGeoURN(value string) string {
+	value = strings.TrimSpace(value)
+	if geoIDRe.MatchString(value) {
+		return "urn:li:geo:" + value
+	}
+	if m := geoURNRe.FindStringSubmatch(value); m != nil {
+		return "urn:li:geo:" + m[1]
+	}
+	return ""
+}
+
+// geoID returns the numeric id of a geo URN
+func geoID(urn string) string {
+	return strings.TrimPrefix(urn, "urn:li:geo:")
+}
+
+// normalizeLocation lowercases a location name and collapses whitespace
+func normalizeLocation(location string) string {
+	location = strings.ToLower(strings.TrimSpace(location))
+	location = strings.Trim(location, ",. ")
+	return whitespaceRe.ReplaceAllString(location, " ")
+}
@@ -0,0 +1,192 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"linkedin-automation/pkg/database"
+	"linkedin-automation/pkg/metrics"
+)
+
+// RunSummary describes one run of a saved search
+type RunSummary struct {
+	RunID              int64         `json:"run_id"`
+	Pages              int           `json:"pages"`
+	CardsSeen          int           `json:"cards_seen"`
+	ProfilesSeen       int           `json:"profiles_seen"`
+	ProfilesNew        int           `json:"profiles_new"`
+	Duplicates         int           `json:"duplicates"`
+	ExtractionFailures int           `json:"extraction_failures"`
+	Duration           time.Duration `json:"duration_ns"`
+	// StoppedEarly is set when a page held only profiles found by earlier runs
+	StoppedEarly bool `json:"stopped_early"`
+	// Limited is set when search.max_results ended the run with pages left;
+	// "search -resume" continues it
+	Limited bool `json:"limited"`
+}
+
+// SaveSearch stores params under name so the search can be re-run later
+func SaveSearch(db *database.DB, name string, params SearchParams, interval time.Duration) error {
+	if err := params.normalizeKeywords(); err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to encode search parameters: %w", err)
+	}
+	return db.SaveSearch(name, string(encoded), int(interval/time.Minute))
+}
+
+// ParseParams decodes the parameters of a saved search
+func ParseParams(saved *database.SavedSearch) (SearchParams, error) {
+	var params SearchParams
+	if err := json.Unmarshal([]byte(saved.Params), &params); err != nil {
+		return params, fmt.Errorf("invalid parameters for saved search %q: %w", saved.Name, err)
+	}
+	if err := params.normalizeKeywords(); err != nil {
+		return params, fmt.Errorf("saved search %q: %w", saved.Name, err)
+	}
+	return params, nil
+}
+
+// RunSavedSearch runs a saved search and records every profile it finds
+// against the search. Profiles no earlier run found are tagged with this
+// run's id. Pagination stops at the first page made up entirely of profiles
+// that earlier runs already found.
+func (s *Search) RunSavedSearch(saved *database.SavedSearch) (*RunSummary, error) {
+	// Fail before a run is recorded if the parameters cannot be used
+	if _, err := ParseParams(saved); err != nil {
+		return nil, err
+	}
+
+	runID, err := s.db.StartSearchRun(saved.ID, saved.Params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record search run: %w", err)
+	}
+
+	log.Info("Running saved search",
+		"name", saved.Name,
+		"search_run_id", runID,
+	)
+
+	return s.runSaved(saved, &database.SearchRun{ID: runID, SavedSearchID: saved.ID, Params: saved.Params})
+}
+
+// runSaved runs a saved search from the page after run.LastPage
+func (s *Search) runSaved(saved *database.SavedSearch, run *database.SearchRun) (*RunSummary, error) {
+	params, err := ParseParams(saved)
+	if err != nil {
+		// A resumed run was reopened as running; record why it stopped
+		s.finishRun(run, false, err)
+		return nil, err
+	}
+
+	company := s.companyFor(params)
+
+	searchURL, err := s.buildSearchURL(params)
+	if err != nil {
+		s.finishRun(run, false, err)
+		return runSummary(run), err
+	}
+
+	// max_results applies to each session, as in searchFrom
+	base := run.ProfilesSeen
+
+	stoppedEarly, limited := false, false
+	err = s.paginate(run, searchURL, func(pageNum int, pageProfiles []Profile) bool {
+		added, known := 0, 0
+		for _, profile := range pageProfiles {
+			if run.ProfilesSeen-base >= s.config.Search.MaxResults {
+				limited = true
+				break
+			}
+			run.ProfilesSeen++
+
+			profile.FoundAt = time.Now()
+			if err := s.saveProfile(profile); err != nil {
+				log.Debug("Failed to save profile",
+					"url", profile.URL,
+					"error", err,
+				)
+			}
+			s.linkCompany(profile.URL, company)
+
+			isNew, err := s.db.RecordSearchResult(saved.ID, run.ID, profile.URL)
+			if err != nil {
+				log.Warn("Failed to record search result",
+					"url", profile.URL,
+					"error", err,
+				)
+				continue
+			}
+			if isNew {
+				added++
+			} else {
+				known++
+			}
+		}
+
+		run.ProfilesNew += added
+		metrics.ProfilesFound.Add(float64(added), "search")
+		run.Duplicates += known
+		// A page cut short by the limit is read again on resume; results
+		// recorded already count as known then
+		if !limited {
+			s.checkpoint(run, pageNum)
+		}
+
+		log.Info("Processed saved search page",
+			"name", saved.Name,
+			"page", pageNum,
+			"new", added,
+			"known", known,
+		)
+
+		if limited {
+			return false
+		}
+		if len(pageProfiles) > 0 && known == len(pageProfiles) {
+			stoppedEarly = true
+			return false
+		}
+		return true
+	})
+	s.finishRun(run, limited, err)
+
+	summary := runSummary(run)
+	summary.StoppedEarly = stoppedEarly
+
+	if err != nil {
+		return summary, fmt.Errorf("saved search %q stopped after page %d, continue it with \"search -resume\": %w",
+			saved.Name, run.LastPage, err)
+	}
+
+	log.Info("Saved search completed",
+		"name", saved.Name,
+		"search_run_id", run.ID,
+		"pages", summary.Pages,
+		"profiles_seen", summary.ProfilesSeen,
+		"profiles_new", summary.ProfilesNew,
+		"duplicates", summary.Duplicates,
+		"stopped_early", summary.StoppedEarly,
+		"duration", summary.Duration.Round(time.Second).String(),
+	)
+
+	return summary, nil
+}
+
+func runSummary(run *database.SearchRun) *RunSummary {
+	return &RunSummary{
+		RunID:              run.ID,
+		Pages:              run.Pages,
+		CardsSeen:          run.CardsSeen,
+		ProfilesSeen:       run.ProfilesSeen,
+		ProfilesNew:        run.ProfilesNew,
+		Duplicates:         run.Duplicates,
+		ExtractionFailures: run.ExtractionFailures,
+		Duration:           run.Duration,
+		Limited:            run.Status == database.SearchRunStopped,
+	}
+}
@@ -1,32 +1,67 @@
 package search
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"linkedin-automation/pkg/artifacts"
 	"linkedin-automation/pkg/config"
 	"linkedin-automation/pkg/database"
 	"linkedin-automation/pkg/logger"
+	"linkedin-automation/pkg/metrics"
+	"linkedin-automation/pkg/profileurl"
+	"linkedin-automation/pkg/query"
+	"linkedin-automation/pkg/selectors"
 	stealthpkg "linkedin-automation/pkg/stealth"
 
 	"github.com/go-rod/rod"
 )
 
+var log = logger.Component("search")
+
 // Search handles LinkedIn profile search
 type Search struct {
-	config  *config.Config
-	page    *rod.Page
-	stealth *stealthpkg.Stealth
-	db      *database.DB
+	config    *config.Config
+	page      *rod.Page
+	stealth   *stealthpkg.Stealth
+	db        *database.DB
+	locations *LocationResolver
 }
 
-// SearchParams represents search parameters
+// SearchParams represents search parameters. The slice fields are facets
+// that accept several values each; companies, industries, schools and service
+// categories are given as LinkedIn ids, either bare ("1441") or as URNs
+// ("urn:li:company:1441").
 type SearchParams struct {
-	JobTitle string
-	Location string
-	Keywords string
+	JobTitle string `json:"job_title,omitempty"`
+	Location string `json:"location,omitempty"`
+	Keywords string `json:"keywords,omitempty"`
+
+	Locations         []string `json:"locations,omitempty"` // further location names or geo URNs, combined with Location
+	CurrentCompanies  []string `json:"current_companies,omitempty"`
+	PastCompanies     []string `json:"past_companies,omitempty"`
+	Industries        []string `json:"industries,omitempty"`
+	Schools           []string `json:"schools,omitempty"`
+	ConnectionDegrees []int    `json:"connection_degrees,omitempty"` // 1, 2 or 3, where 3 means 3rd and beyond
+	ProfileLanguages  []string `json:"profile_languages,omitempty"`  // ISO 639-1 codes such as "en"
+	ServiceCategories []string `json:"service_categories,omitempty"`
+}
+
+// normalizeKeywords validates the boolean keyword query and stores it in the
+// form it is sent to LinkedIn, so a typo fails the search instead of quietly
+// returning unrelated profiles
+func (p *SearchParams) normalizeKeywords() error {
+	keywords, err := query.Normalize(p.Keywords)
+	if err != nil {
+		return fmt.Errorf("invalid keyword query: %w", err)
+	}
+	p.Keywords = keywords
+	return nil
 }
 
 // Profile represents a LinkedIn profile
@@ -40,106 +75,310 @@ type Profile struct {
 	JobTitle   string    `json:"job_title"`
 	ProfilePic string    `json:"profile_pic"`
 	FoundAt    time.Time `json:"found_at"`
+
+	// Degree is 1, 2 or 3, or 0 when the card shows no degree badge
+	Degree                int      `json:"degree"`
+	MutualConnections     int      `json:"mutual_connections"`
+	MutualConnectionNames []string `json:"mutual_connection_names,omitempty"`
+	// Action is the primary button on the card: "connect", "message" or "follow"
+	Action string `json:"action"`
 }
 
+var (
+	degreeRe        = regexp.MustCompile(`\b([123])(?:st|nd|rd)\b`)
+	mutualCountRe   = regexp.MustCompile(`(?i)^(\d+)\s+mutual connections?$`)
+	mutualOtherRe   = regexp.MustCompile(`(?i)(?:,\s*|\s+)(?:and\s+)?(\d+)\s+other mutual connections?$`)
+	mutualNamedRe   = regexp.MustCompile(`(?i)\s+(?:is a|are)\s+mutual connections?$`)
+	nameSeparatorRe = regexp.MustCompile(`\s*,\s*(?:and\s+)?|\s+and\s+`)
+)
+
 // NewSearch creates a new search instance
 func NewSearch(cfg *config.Config, page *rod.Page, stealth *stealthpkg.Stealth, db *database.DB) *Search {
 	return &Search{
-		config:  cfg,
-		page:    page,
-		stealth: stealth,
-		db:      db,
+		config:    cfg,
+		page:      page,
+		stealth:   stealth,
+		db:        db,
+		locations: NewLocationResolver(cfg, page, db),
 	}
 }
 
-// SearchProfiles searches for profiles based on parameters
+// SearchProfiles searches for profiles based on parameters. Progress is
+// checkpointed in search_runs after every page, so a search that stops early
+// can be continued with ResumeSearch.
 func (s *Search) SearchProfiles(params SearchParams) ([]Profile, error) {
-	logger.Info("Starting profile search", map[string]interface{}{
-		"job_title": params.JobTitle,
-		"location":  params.Location,
-		"keywords":  params.Keywords,
-	})
+	if err := params.normalizeKeywords(); err != nil {
+		return nil, err
+	}
 
-	// Build search URL
-	searchURL := s.buildSearchURL(params)
+	log.Info("Starting profile search",
+		"job_title", params.JobTitle,
+		"location", params.Location,
+		"keywords", params.Keywords,
+		"current_companies", len(params.CurrentCompanies),
+		"connection_degrees", params.ConnectionDegrees,
+	)
 
-	// Navigate to search page
-	if err := s.page.Navigate(searchURL); err != nil {
-		return nil, fmt.Errorf("failed to navigate to search page: %w", err)
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode search parameters: %w", err)
 	}
 
-	s.page.MustWaitLoad()
+	run := &database.SearchRun{Params: string(encoded)}
+	if run.ID, err = s.db.StartSearchRun(0, run.Params); err != nil {
+		return nil, fmt.Errorf("failed to record search run: %w", err)
+	}
 
-	// Scroll to load more results
-	s.stealth.ScrollHumanLike(1000)
-	s.stealth.RandomDelay()
+	return s.searchFrom(params, run)
+}
 
-	var profiles []Profile
-	pageNum := 1
+// searchFrom runs an ad-hoc search from the page after run.LastPage, saving
+// profiles that are not in the database yet
+func (s *Search) searchFrom(params SearchParams, run *database.SearchRun) ([]Profile, error) {
+	company := s.companyFor(params)
 
-	for len(profiles) < s.config.Search.MaxResults {
-		logger.Info("Processing search page", map[string]interface{}{
-			"page":           pageNum,
-			"profiles_found": len(profiles),
-		})
+	searchURL, err := s.buildSearchURL(params)
+	if err != nil {
+		s.finishRun(run, false, err)
+		return nil, err
+	}
 
-		// Extract profiles from current page
-		pageProfiles, err := s.extractProfilesFromPage()
-		if err != nil {
-			logger.Warn("Failed to extract profiles from page", map[string]interface{}{
-				"error": err.Error(),
-			})
-			break
-		}
+	// max_results applies to each session, so a resumed run finds up to
+	// that many more profiles
+	base := run.ProfilesNew
+	limited := false
+
+	var profiles []Profile
+	err = s.paginate(run, searchURL, func(pageNum int, pageProfiles []Profile) bool {
+		log.Info("Processing search page",
+			"page", pageNum,
+			"profiles_found", run.ProfilesNew,
+		)
 
 		// Filter duplicates and save to database
 		for _, profile := range pageProfiles {
-			if len(profiles) >= s.config.Search.MaxResults {
-				break
+			if run.ProfilesNew-base >= s.config.Search.MaxResults {
+				// The page is not checkpointed, so a resume reads the
+				// rest of it
+				limited = true
+				return false
 			}
+			run.ProfilesSeen++
 
 			// Check if profile already exists
 			if s.profileExists(profile.URL) {
+				run.Duplicates++
+				// Degree, mutual connections and the card action
+				// change over time; keep them current
+				if err := s.saveProfile(profile); err != nil {
+					log.Debug("Failed to update profile",
+						"url", profile.URL,
+						"error", err,
+					)
+				}
+				s.linkCompany(profile.URL, company)
 				continue
 			}
 
 			profile.FoundAt = time.Now()
 			profiles = append(profiles, profile)
+			run.ProfilesNew++
+			metrics.ProfilesFound.Inc("search")
 
 			// Save to database
 			if err := s.saveProfile(profile); err != nil {
-				logger.Debug("Failed to save profile", map[string]interface{}{
-					"url":   profile.URL,
-					"error": err.Error(),
-				})
+				log.Debug("Failed to save profile",
+					"url", profile.URL,
+					"error", err,
+				)
 			}
+			s.linkCompany(profile.URL, company)
+		}
+
+		s.checkpoint(run, pageNum)
+		if run.ProfilesNew-base >= s.config.Search.MaxResults {
+			limited = true
+			return false
+		}
+		return true
+	})
+	s.finishRun(run, limited, err)
+
+	if err != nil {
+		return profiles, fmt.Errorf("search stopped after page %d, continue it with \"search -resume\": %w",
+			run.LastPage, err)
+	}
+
+	if limited {
+		log.Info("Search reached max_results, continue it with \"search -resume\"",
+			"search_run_id", run.ID,
+			"last_page", run.LastPage,
+			"total_profiles", run.ProfilesNew,
+		)
+		return profiles, nil
+	}
+
+	log.Info("Search completed",
+		"search_run_id", run.ID,
+		"total_profiles", run.ProfilesNew,
+		"duplicates", run.Duplicates,
+		"extraction_failures", run.ExtractionFailures,
+		"duration", run.Duration.Round(time.Second).String(),
+	)
+
+	return profiles, nil
+}
+
+// ResumeSearch continues an unfinished run, ad-hoc or saved, from the page
+// after its last completed page
+func (s *Search) ResumeSearch(run *database.SearchRun) (*RunSummary, error) {
+	if run.Status == database.SearchRunCompleted {
+		return nil, fmt.Errorf("search run %d already completed", run.ID)
+	}
+
+	if err := s.db.ReopenSearchRun(run.ID); err != nil {
+		return nil, fmt.Errorf("failed to reopen search run: %w", err)
+	}
+
+	log.Info("Resuming search",
+		"search_run_id", run.ID,
+		"from_page", run.LastPage+1,
+	)
+
+	if run.SavedSearchID != 0 {
+		saved, err := s.db.GetSavedSearchByID(run.SavedSearchID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load saved search: %w", err)
+		}
+		if saved == nil {
+			return nil, fmt.Errorf("saved search of run %d no longer exists", run.ID)
+		}
+		return s.runSaved(saved, run)
+	}
+
+	var params SearchParams
+	if err := json.Unmarshal([]byte(run.Params), &params); err != nil {
+		return nil, fmt.Errorf("invalid parameters for search run %d: %w", run.ID, err)
+	}
+
+	_, err := s.searchFrom(params, run)
+	return runSummary(run), err
+}
+
+// checkpoint records that a page was completed
+func (s *Search) checkpoint(run *database.SearchRun, pageNum int) {
+	run.Pages++
+	run.LastPage = pageNum
+	if err := s.db.UpdateSearchRun(run); err != nil {
+		log.Warn("Failed to checkpoint search run",
+			"search_run_id", run.ID,
+			"page", pageNum,
+			"error", err,
+		)
+	}
+}
+
+// finishRun records the final state of a run. Runs that failed or were
+// limited by max_results stay resumable; only a run whose results ran out
+// is completed.
+func (s *Search) finishRun(run *database.SearchRun, limited bool, err error) {
+	switch {
+	case err != nil:
+		run.Status = database.SearchRunFailed
+		run.Error = err.Error()
+	case limited:
+		run.Status = database.SearchRunStopped
+	default:
+		run.Status = database.SearchRunCompleted
+	}
+
+	if ferr := s.db.FinishSearchRun(run); ferr != nil {
+		log.Warn("Failed to finish search run",
+			"search_run_id", run.ID,
+			"error", ferr,
+		)
+	}
+}
+
+// paginate opens a search URL at the page after run.LastPage and hands the
+// profiles of each results page to handle, moving on to the next page until
+// handle returns false or there are no more pages. The URL, the cards read
+// and the time spent are recorded on run.
+func (s *Search) paginate(run *database.SearchRun, searchURL string, handle func(pageNum int, profiles []Profile) bool) error {
+	run.SearchURL = searchURL
+
+	// Duration accumulates across resumes
+	started, spent := time.Now(), run.Duration
+	defer func() { run.Duration = spent + time.Since(started) }()
+
+	startPage := run.LastPage + 1
+	if startPage > 1 {
+		searchURL += "&page=" + strconv.Itoa(startPage)
+	}
+
+	// Navigate to search page
+	loadStart := time.Now()
+	if err := s.page.Navigate(searchURL); err != nil {
+		return s.pageFailed(startPage, fmt.Errorf("failed to navigate to search page: %w", err))
+	}
+
+	s.page.MustWaitLoad()
+	metrics.ObservePageLoad("search", loadStart)
+
+	// Scroll to load more results
+	s.stealth.ScrollHumanLike(1000)
+	s.stealth.RandomDelay()
+
+	for pageNum := startPage; ; pageNum++ {
+		// Extract profiles from current page
+		pageProfiles, failures, err := s.extractProfilesFromPage()
+		if err != nil {
+			return s.pageFailed(pageNum, fmt.Errorf("failed to extract profiles from page %d: %w", pageNum, err))
+		}
+		if failures > 0 {
+			log.Warn("Some result cards could not be read",
+				"page", pageNum,
+				"failures", failures,
+				artifacts.Capture(s.page, "search", fmt.Errorf("%d result cards on page %d could not be read", failures, pageNum)),
+			)
+		}
+		run.CardsSeen += len(pageProfiles) + failures
+		run.ExtractionFailures += failures
+		run.Duration = spent + time.Since(started)
+
+		if !handle(pageNum, pageProfiles) {
+			return nil
 		}
 
 		// Check if there's a next page
 		if !s.hasNextPage() {
-			break
+			return nil
 		}
 
 		// Go to next page
 		if err := s.goToNextPage(); err != nil {
-			logger.Warn("Failed to go to next page", map[string]interface{}{
-				"error": err.Error(),
-			})
-			break
+			return s.pageFailed(pageNum, fmt.Errorf("failed to go to next page: %w", err))
 		}
 
-		pageNum++
 		time.Sleep(time.Duration(s.config.Search.PaginationDelay) * time.Millisecond)
 	}
+}
 
-	logger.Info("Search completed", map[string]interface{}{
-		"total_profiles": len(profiles),
-	})
-
-	return profiles, nil
+// pageFailed logs a failure on a results page with the page's artefacts and
+// returns err
+func (s *Search) pageFailed(pageNum int, err error) error {
+	log.Warn("Search page failed",
+		"page", pageNum,
+		"error", err,
+		artifacts.Capture(s.page, "search", err),
+	)
+	return err
 }
 
-func (s *Search) buildSearchURL(params SearchParams) string {
+// buildSearchURL returns the people search URL for params. A location that
+// cannot be resolved is an error: leaving it out would widen the search to
+// everywhere.
+func (s *Search) buildSearchURL(params SearchParams) (string, error) {
 	baseURL := s.config.LinkedIn.BaseURL + "/search/results/people/"
 
 	queryParams := url.Values{}
@@ -152,45 +391,125 @@ func (s *Search) buildSearchURL(params SearchParams) string {
 		queryParams.Set("title", params.JobTitle)
 	}
 
-	if params.Location != "" {
-		queryParams.Set("geoUrn", s.getLocationURN(params.Location))
+	var geoIDs []string
+	for _, location := range append([]string{params.Location}, params.Locations...) {
+		if strings.TrimSpace(location) == "" {
+			continue
+		}
+		urn, err := s.locations.Resolve(location)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve location %q: %w", location, err)
+		}
+		geoIDs = append(geoIDs, geoID(urn))
+	}
+	setFacet(queryParams, "geoUrn", geoIDs)
+
+	setFacet(queryParams, "currentCompany", facetIDs(params.CurrentCompanies))
+	setFacet(queryParams, "pastCompany", facetIDs(params.PastCompanies))
+	setFacet(queryParams, "industry", facetIDs(params.Industries))
+	setFacet(queryParams, "schoolFilter", facetIDs(params.Schools))
+	setFacet(queryParams, "serviceCategory", facetIDs(params.ServiceCategories))
+
+	var network []string
+	for _, degree := range params.ConnectionDegrees {
+		if code, ok := networkCodes[degree]; ok {
+			network = append(network, code)
+		} else {
+			log.Warn("Ignoring unsupported connection degree", "degree", degree)
+		}
+	}
+	setFacet(queryParams, "network", network)
+
+	var languages []string
+	for _, lang := range params.ProfileLanguages {
+		if lang = strings.ToLower(strings.TrimSpace(lang)); lang != "" {
+			languages = append(languages, lang)
+		}
 	}
+	setFacet(queryParams, "profileLanguage", languages)
 
 	queryParams.Set("origin", "SWITCH_SEARCH_VERTICAL")
 
-	return baseURL + "?" + queryParams.Encode()
+	return baseURL + "?" + queryParams.Encode(), nil
+}
+
+// networkCodes maps connection degrees to values of the network facet
+var networkCodes = map[int]string{
+	1: "F",
+	2: "S",
+	3: "O",
+}
+
+// setFacet adds a facet parameter in LinkedIn's list form, e.g.
+// currentCompany=["1441","1035"]. Duplicate values are dropped and an empty
+// list leaves the parameter out.
+func setFacet(q url.Values, name string, values []string) {
+	var unique []string
+	seen := make(map[string]bool)
+	for _, v := range values {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	if len(unique) == 0 {
+		return
+	}
+
+	encoded, _ := json.Marshal(unique)
+	q.Set(name, string(encoded))
 }
 
-func (s *Search) getLocationURN(location string) string {
-	// This would need a mapping of locations to LinkedIn URNs
-	// For now, return a placeholder
-	return "urn:li:geo:103644278" // United States
+// facetIDs reduces URNs such as "urn:li:fsd_company:1441" to their id
+func facetIDs(values []string) []string {
+	var ids []string
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if i := strings.LastIndex(v, ":"); i >= 0 {
+			v = v[i+1:]
+		}
+		if v != "" {
+			ids = append(ids, v)
+		}
+	}
+	return ids
 }
 
-func (s *Search) extractProfilesFromPage() ([]Profile, error) {
+// extractProfilesFromPage reads the result cards on the current page,
+// returning the profiles and the number of cards that could not be read
+func (s *Search) extractProfilesFromPage() ([]Profile, int, error) {
 	var profiles []Profile
 
 	// Find profile cards
-	profileCards := s.page.MustElements("div[data-chameleon-result-urn]")
+	profileCards, err := selectors.FindAll(s.page, "search_result_card")
+	if err != nil {
+		return nil, 0, err
+	}
 
+	failures := 0
 	for _, card := range profileCards {
 		profile, err := s.extractProfileFromCard(card)
 		if err != nil {
-			continue // Skip problematic profiles
+			// Skip problematic profiles
+			failures++
+			log.Debug("Failed to extract profile from card",
+				"error", err,
+			)
+			continue
 		}
 
 		profiles = append(profiles, profile)
 	}
 
-	return profiles, nil
+	return profiles, failures, nil
 }
 
 func (s *Search) extractProfileFromCard(card *rod.Element) (Profile, error) {
 	profile := Profile{}
 
 	// Extract profile URL
-	linkEl := card.MustElement("a[href*='/in/']")
-	if linkEl == nil {
+	linkEl, err := selectors.Find(card, "search_result_link")
+	if err != nil {
 		return profile, fmt.Errorf("no profile link found")
 	}
 
@@ -199,47 +518,115 @@ func (s *Search) extractProfileFromCard(card *rod.Element) (Profile, error) {
 		return profile, err
 	}
 
-	// Clean up URL
-	if strings.Contains(*href, "?") {
-		profile.URL = strings.Split(*href, "?")[0]
-	} else {
-		profile.URL = *href
-	}
+	profile.URL = profileurl.Canonical(*href)
 
 	// Extract name
-	nameEl := card.MustElement("span[aria-hidden='true']")
-	if nameEl != nil {
-		name, _ := nameEl.Text()
-		profile.Name = strings.TrimSpace(name)
-	}
+	profile.Name = selectors.Text(card, "search_result_name")
 
 	// Extract headline
-	headlineEl := card.MustElement("div[data-anonymize='job-title']")
-	if headlineEl != nil {
-		headline, _ := headlineEl.Text()
-		profile.Headline = strings.TrimSpace(headline)
-	}
+	profile.Headline = selectors.Text(card, "search_result_headline")
 
 	// Extract location
-	locationEl := card.MustElement("div[data-anonymize='location']")
-	if locationEl != nil {
-		location, _ := locationEl.Text()
-		profile.Location = strings.TrimSpace(location)
+	profile.Location = selectors.Text(card, "search_result_location")
+
+	// Extract connection degree, e.g. "• 2nd"
+	if badge := selectors.Text(card, "search_result_degree"); badge != "" {
+		profile.Degree = parseDegree(badge)
+	}
+
+	// Extract shared connections, e.g. "Jane Doe and 12 other mutual connections"
+	if insight := selectors.Text(card, "search_result_insight"); insight != "" {
+		profile.MutualConnections, profile.MutualConnectionNames = parseMutualConnections(insight)
+	}
+
+	// Extract the primary action offered on the card
+	if actionEl, err := selectors.Find(card, "search_result_action"); err == nil {
+		label, _ := actionEl.Attribute("aria-label")
+		text, _ := actionEl.Text()
+		if label != nil {
+			text = *label + " " + text
+		}
+		profile.Action = parseCardAction(text)
 	}
 
 	return profile, nil
 }
 
+// parseDegree extracts the connection degree from a badge such as "• 2nd"
+func parseDegree(badge string) int {
+	m := degreeRe.FindStringSubmatch(badge)
+	if m == nil {
+		return 0
+	}
+	degree, _ := strconv.Atoi(m[1])
+	return degree
+}
+
+// parseMutualConnections reads the shared-connection insight shown on a card.
+// LinkedIn names up to two people and summarises the rest, as in
+// "Jane Doe, John Smith and 5 other mutual connections".
+func parseMutualConnections(insight string) (int, []string) {
+	insight = strings.Join(strings.Fields(insight), " ")
+	if insight == "" {
+		return 0, nil
+	}
+
+	if m := mutualCountRe.FindStringSubmatch(insight); m != nil {
+		count, _ := strconv.Atoi(m[1])
+		return count, nil
+	}
+
+	others := 0
+	var namesPart string
+	if m := mutualOtherRe.FindStringSubmatchIndex(insight); m != nil {
+		others, _ = strconv.Atoi(insight[m[2]:m[3]])
+		namesPart = insight[:m[0]]
+	} else if m := mutualNamedRe.FindStringIndex(insight); m != nil {
+		namesPart = insight[:m[0]]
+	} else {
+		return 0, nil
+	}
+
+	var names []string
+	for _, name := range nameSeparatorRe.Split(namesPart, -1) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return len(names) + others, names
+}
+
+// parseCardAction classifies the label of a card's primary button
+func parseCardAction(label string) string {
+	label = strings.ToLower(label)
+	switch {
+	case strings.Contains(label, "connect"):
+		return "connect"
+	case strings.Contains(label, "message"):
+		return "message"
+	case strings.Contains(label, "follow"):
+		return "follow"
+	default:
+		return ""
+	}
+}
+
 func (s *Search) hasNextPage() bool {
-	nextBtn := s.page.MustElements("button[aria-label='Next']")
-	return len(nextBtn) > 0 && nextBtn[0].MustVisible()
+	nextBtn, err := selectors.Find(s.page, "search_next_button")
+	return err == nil && nextBtn.MustVisible()
 }
 
 func (s *Search) goToNextPage() error {
-	nextBtn := s.page.MustElement("button[aria-label='Next']")
+	nextBtn, err := selectors.Find(s.page, "search_next_button")
+	if err != nil {
+		return fmt.Errorf("failed to find next page button: %w", err)
+	}
 
-	s.stealth.HumanClick(nextBtn.First)
+	loadStart := time.Now()
+	s.stealth.HumanClick(nextBtn)
 	s.page.MustWaitLoad()
+	metrics.ObservePageLoad("search", loadStart)
 
 	return nil
 }
@@ -252,11 +639,16 @@ func (s *Search) profileExists(url string) bool {
 }
 
 func (s *Search) saveProfile(profile Profile) error {
-	_, err := s.db.Exec(`
-		INSERT INTO profiles (url, name, headline, location, found_at)
-		VALUES (?, ?, ?, ?, ?)
-		ON CONFLICT(url) DO NOTHING
-	`, profile.URL, profile.Name, profile.Headline, profile.Location, profile.FoundAt)
-
-	return err
+	return s.db.AddProfile(&database.Profile{
+		URL:                   profile.URL,
+		Name:                  profile.Name,
+		Headline:              profile.Headline,
+		Location:              profile.Location,
+		Source:                "search",
+		ConnectionDegree:      profile.Degree,
+		MutualConnections:     profile.MutualConnections,
+		MutualConnectionNames: profile.MutualConnectionNames,
+		CardAction:            profile.Action,
+		FoundAt:               profile.FoundAt,
+	})
 }
@@ -0,0 +1,213 @@
+package selectors
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"linkedin-automation/pkg/logger"
+
+	"github.com/go-rod/rod"
+	"gopkg.in/yaml.v3"
+)
+
+var log = logger.Component("selectors")
+
+// DefaultPath is where the selectors file is read from when the config does
+// not name one
+const DefaultPath = "config/selectors.yaml"
+
+// pollInterval is how often Wait looks for an element again
+const pollInterval = 250 * time.Millisecond
+
+// Registry maps logical page elements, such as connect_button, to an ordered
+// list of CSS selectors. The first selector that matches wins, so the
+// current markup goes first and older variants follow as fallbacks.
+type Registry struct {
+	Version   int                 `yaml:"version"`
+	Selectors map[string][]string `yaml:"selectors"`
+
+	// fallbacks remembers which elements already logged a fallback match
+	fallbacks sync.Map
+}
+
+// Finder is satisfied by both *rod.Page and *rod.Element
+type Finder interface {
+	Has(selector string) (bool, *rod.Element, error)
+	Elements(selector string) (rod.Elements, error)
+}
+
+var global = &Registry{}
+
+// Load reads a selectors file and makes it the registry used by the package
+// functions
+func Load(path string) (*Registry, error) {
+	if path == "" {
+		path = DefaultPath
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read selectors file: %w", err)
+	}
+
+	r := &Registry{}
+	if err := yaml.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("failed to parse selectors file: %w", err)
+	}
+
+	if r.Version <= 0 {
+		return nil, fmt.Errorf("selectors file %s has no version", path)
+	}
+	for name, list := range r.Selectors {
+		if len(list) == 0 {
+			return nil, fmt.Errorf("selectors file %s: %s has no selectors", path, name)
+		}
+	}
+
+	global = r
+	log.Info("Selectors loaded",
+		"path", path,
+		"version", r.Version,
+		"elements", len(r.Selectors),
+	)
+
+	return r, nil
+}
+
+// Get returns the fallback list for an element
+func Get(name string) []string {
+	return global.Selectors[name]
+}
+
+// Find returns the first element matching one of the selectors for name
+func Find(f Finder, name string) (*rod.Element, error) {
+	return global.find(f, name, nil)
+}
+
+// FindFunc is like Find but skips matches that accept rejects
+func FindFunc(f Finder, name string, accept func(*rod.Element) bool) (*rod.Element, error) {
+	return global.find(f, name, accept)
+}
+
+// FindAll returns the elements matched by the first selector for name that
+// matches anything. No match is not an error.
+func FindAll(f Finder, name string) (rod.Elements, error) {
+	list, err := global.list(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, selector := range list {
+		els, err := f.Elements(selector)
+		if err != nil || len(els) == 0 {
+			continue
+		}
+		global.matched(name, selector, i)
+		return els, nil
+	}
+
+	return nil, nil
+}
+
+// Has reports whether any selector for name matches
+func Has(f Finder, name string) bool {
+	el, err := Find(f, name)
+	return err == nil && el != nil
+}
+
+// Matches reports whether el itself, rather than an element within it,
+// matches one of the selectors for name
+func Matches(el *rod.Element, name string) bool {
+	list, err := global.list(name)
+	if err != nil {
+		return false
+	}
+
+	for i, selector := range list {
+		if ok, err := el.Matches(selector); err == nil && ok {
+			global.matched(name, selector, i)
+			return true
+		}
+	}
+	return false
+}
+
+// Text returns the trimmed text of the element found for name, or an empty
+// string
+func Text(f Finder, name string) string {
+	el, err := Find(f, name)
+	if err != nil {
+		return ""
+	}
+	text, err := el.Text()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(text)
+}
+
+// Wait polls until an element for name appears or timeout passes
+func Wait(f Finder, name string, timeout time.Duration) (*rod.Element, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		el, err := Find(f, name)
+		if err == nil {
+			return el, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out after %s: %w", timeout, err)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+func (r *Registry) find(f Finder, name string, accept func(*rod.Element) bool) (*rod.Element, error) {
+	list, err := r.list(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, selector := range list {
+		found, el, err := f.Has(selector)
+		if err != nil || !found {
+			continue
+		}
+		if accept != nil && !accept(el) {
+			continue
+		}
+		r.matched(name, selector, i)
+		return el, nil
+	}
+
+	return nil, fmt.Errorf("%s not found", name)
+}
+
+func (r *Registry) list(name string) ([]string, error) {
+	list, ok := r.Selectors[name]
+	if !ok {
+		return nil, fmt.Errorf("no selectors configured for %s", name)
+	}
+	return list, nil
+}
+
+// matched logs which selector matched. A fallback matching usually means
+// LinkedIn changed its markup, so the first one per element is a warning.
+func (r *Registry) matched(name, selector string, index int) {
+	l := log.With(
+		"element", name,
+		"selector", selector,
+		"fallback", index,
+		"version", r.Version,
+	)
+
+	if index > 0 {
+		if _, seen := r.fallbacks.LoadOrStore(name, index); !seen {
+			l.Warn("Selector fallback matched")
+			return
+		}
+	}
+	l.Debug("Selector matched")
+}
@@ -0,0 +1,133 @@
+package templates
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"linkedin-automation/pkg/database"
+)
+
+// NoteLimit is the maximum length LinkedIn accepts for an invitation note
+const NoteLimit = 300
+
+// Issue severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Source is a template to lint together with how it is personalised
+type Source struct {
+	Location  string // where the template lives, e.g. "messaging.message_templates[1]"
+	Text      string
+	Vars      func(profile *database.Profile) map[string]string
+	MaxLength int  // 0 for no limit
+	Spintax   bool // whether {a|b} groups are expanded when the text is sent
+}
+
+// Issue is a single lint finding
+type Issue struct {
+	Severity string
+	Location string
+	Message  string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s %s: %s", strings.ToUpper(i.Severity), i.Location, i.Message)
+}
+
+// Lint checks every source for placeholders its personaliser does not know,
+// placeholders that are blank for more than emptyThreshold of the profiles,
+// and renderings that exceed the source's length limit
+func Lint(sources []Source, profiles []*database.Profile, emptyThreshold float64) []Issue {
+	var issues []Issue
+
+	for _, src := range sources {
+		known := src.Vars(&database.Profile{})
+
+		for _, name := range Placeholders(src.Text) {
+			if _, ok := known[name]; !ok {
+				issues = append(issues, Issue{
+					Severity: SeverityError,
+					Location: src.Location,
+					Message:  fmt.Sprintf("unknown placeholder {%s}, expected one of %s", name, knownList(known)),
+				})
+				continue
+			}
+
+			if len(profiles) == 0 {
+				continue
+			}
+
+			empty := 0
+			for _, p := range profiles {
+				if strings.TrimSpace(src.Vars(p)[name]) == "" {
+					empty++
+				}
+			}
+
+			if share := float64(empty) / float64(len(profiles)); share > emptyThreshold {
+				issues = append(issues, Issue{
+					Severity: SeverityWarning,
+					Location: src.Location,
+					Message: fmt.Sprintf("{%s} is blank for %.0f%% of profiles (%d of %d)",
+						name, share*100, empty, len(profiles)),
+				})
+			}
+		}
+
+		if src.MaxLength > 0 {
+			issues = append(issues, lintLength(src, profiles)...)
+		}
+	}
+
+	return issues
+}
+
+// lintLength reports renderings longer than the source's limit. Without
+// stored profiles the raw template is measured instead.
+func lintLength(src Source, profiles []*database.Profile) []Issue {
+	if len(profiles) == 0 {
+		if n := utf8.RuneCountInString(src.Text); n > src.MaxLength {
+			return []Issue{{
+				Severity: SeverityError,
+				Location: src.Location,
+				Message:  fmt.Sprintf("template is %d characters, over the %d character limit", n, src.MaxLength),
+			}}
+		}
+		return nil
+	}
+
+	over, longest := 0, 0
+	for _, p := range profiles {
+		n := utf8.RuneCountInString(Fill(src.Text, src.Vars(p)))
+		if n > src.MaxLength {
+			over++
+		}
+		if n > longest {
+			longest = n
+		}
+	}
+
+	if over == 0 {
+		return nil
+	}
+
+	return []Issue{{
+		Severity: SeverityError,
+		Location: src.Location,
+		Message: fmt.Sprintf("renders over the %d character limit for %d of %d profiles (longest %d)",
+			src.MaxLength, over, len(profiles), longest),
+	}}
+}
+
+func knownList(vars map[string]string) string {
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, "{"+name+"}")
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,138 @@
+package templates
+
+import (
+	"math/rand"
+	"regexp"
+	"strings"
+	"time"
+
+	"linkedin-automation/pkg/config"
+)
+
+var placeholderRe = regexp.MustCompile(`\{([A-Za-z_]+)\}`)
+
+// Picker selects message templates by weight and expands spintax. A fixed
+// seed makes both reproducible.
+type Picker struct {
+	rng *rand.Rand
+}
+
+// NewPicker creates a picker seeded with seed, or with the clock when seed is 0
+func NewPicker(seed int64) *Picker {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Picker{rng: rand.New(rand.NewSource(seed))}
+}
+
+// Choose picks a template at random in proportion to its weight and returns
+// its index and text. Weights below 1 count as 1. The index is -1 when
+// templates is empty.
+func (p *Picker) Choose(templates []config.MessageTemplate) (int, string) {
+	if len(templates) == 0 {
+		return -1, ""
+	}
+
+	total := 0
+	for _, t := range templates {
+		total += weight(t)
+	}
+
+	n := p.rng.Intn(total)
+	for i, t := range templates {
+		if n -= weight(t); n < 0 {
+			return i, t.Text
+		}
+	}
+
+	// Unreachable, but keeps the compiler happy
+	last := len(templates) - 1
+	return last, templates[last].Text
+}
+
+// Spin expands spintax such as "{Hi|Hello|Hey} there" by picking one
+// alternative of every group. Groups may be nested. Braces without a "|",
+// like the {name} placeholder, are left in place for personalisation.
+func (p *Picker) Spin(text string) string {
+	out, _ := p.spin(text, 0, false)
+	return out
+}
+
+// spin expands text starting at pos. Inside a group (nested is true) it stops
+// at the closing brace and returns the position after it.
+func (p *Picker) spin(text string, pos int, nested bool) (string, int) {
+	var alternatives []string
+	var current strings.Builder
+
+	for pos < len(text) {
+		switch c := text[pos]; c {
+		case '{':
+			inner, next := p.spin(text, pos+1, true)
+			current.WriteString(inner)
+			pos = next
+		case '|':
+			if !nested {
+				current.WriteByte(c)
+				pos++
+				continue
+			}
+			alternatives = append(alternatives, current.String())
+			current.Reset()
+			pos++
+		case '}':
+			if !nested {
+				current.WriteByte(c)
+				pos++
+				continue
+			}
+			if alternatives == nil {
+				// Not spintax, keep the placeholder as written
+				return "{" + current.String() + "}", pos + 1
+			}
+			alternatives = append(alternatives, current.String())
+			return alternatives[p.rng.Intn(len(alternatives))], pos + 1
+		default:
+			current.WriteByte(c)
+			pos++
+		}
+	}
+
+	if nested {
+		// Unterminated group: emit it unchanged
+		alternatives = append(alternatives, current.String())
+		return "{" + strings.Join(alternatives, "|"), pos
+	}
+	return current.String(), pos
+}
+
+// Fill replaces {placeholder} variables that have an entry in vars. Unknown
+// placeholders are left as written.
+func Fill(text string, vars map[string]string) string {
+	return placeholderRe.ReplaceAllStringFunc(text, func(match string) string {
+		if value, ok := vars[match[1:len(match)-1]]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// Placeholders returns the names of the placeholders used in text, in order
+// of first appearance
+func Placeholders(text string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range placeholderRe.FindAllStringSubmatch(text, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+func weight(t config.MessageTemplate) int {
+	if t.Weight < 1 {
+		return 1
+	}
+	return t.Weight
+}